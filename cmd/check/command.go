@@ -2,51 +2,108 @@ package check
 
 import (
 	"errors"
+	"fmt"
 
+	"github.com/judimator/augurken/cmd/exitcode"
+	"github.com/judimator/augurken/cmd/internal/shared"
 	"github.com/judimator/augurken/formatter"
 	"github.com/judimator/augurken/log"
+	"github.com/judimator/augurken/report"
 	"github.com/spf13/cobra"
 )
 
+// outputFormats lists the formats of the report of a run
+var outputFormats = []string{
+	report.FormatText,
+	report.FormatJSON,
+	report.FormatSARIF,
+	report.FormatGitHub,
+	report.FormatGitLab,
+}
+
 func NewCommand() *cobra.Command {
-	var indent int
+	var (
+		flags *shared.Flags
+		diff  bool
+		junit string
+	)
 	cmd := &cobra.Command{
-		Use:   "check [file or path]",
+		Use:   "check [files, paths or glob patterns, or - to read stdin]",
 		Short: "Check formatting of gherkin file(s)",
-		Args:  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		Args:  cobra.OnlyValidArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) != 1 {
-				err := errors.New("please, specify file or folder")
+			args, err := flags.Validate(args)
+			if err != nil {
+				return err
+			}
+
+			if flags.Output != report.FormatText && flags.OutputFile == "" && diff {
+				err := errors.New("--output-file must be given to write a report along with --diff")
 				log.Error(err)
 
 				return err
 			}
 
-			success := true
-			indent, _ := cmd.Flags().GetInt("indent")
-			fileManager := formatter.NewFileManager(indent)
-			result := fileManager.Check(args[0])
+			fileManager := flags.FileManager(cmd)
 
-			for _, r := range result {
-				if s, ok := r.(string); ok {
-					log.Success(s)
+			var results formatter.Results
+			if args[0] == shared.StdinPath {
+				results = formatter.Results{fileManager.CheckStream(flags.StdinFilename, cmd.InOrStdin())}
+			} else {
+				results = fileManager.Check(args...)
+			}
+
+			for _, result := range results {
+				flags.LogDetails(result, false)
+
+				if result.Err == nil {
+					log.Success("formatted: " + result.Path)
 
 					continue
 				}
-				if e, ok := r.(error); ok {
-					log.Error(e)
-					success = false
+
+				log.Error(result.Err)
+
+				if diff {
+					fmt.Fprint(cmd.OutOrStdout(), result.Diff())
+				}
+			}
+
+			summary := results.Summary()
+			log.Info(describe(summary))
+
+			if err := flags.WriteReport(cmd, results); err != nil {
+				return err
+			}
+
+			if junit != "" {
+				if err := report.WriteFile(junit, report.FormatJUnit, results); err != nil {
+					log.Error(err)
+
+					return err
 				}
 			}
 
-			if !success {
-				return errors.New("error occurred while formatting file/folder")
+			if code := exitcode.Of(summary, flags.Strict); code != exitcode.OK {
+				return exitcode.Error{Code: code, Err: errors.New("error occurred while formatting file/folder")}
 			}
 
 			return nil
 		},
 	}
-	cmd.Flags().IntVarP(&indent, "indent", "i", 2, "set the indentation for Gherkin features (default 2)")
+	flags = shared.AddFlags(cmd, outputFormats, "check the content of the feature files staged in the git index")
+	cmd.Flags().BoolVar(&diff, "diff", false, "print a unified diff of the changes needed to format each file")
+	cmd.Flags().StringVar(&junit, "junit", "", "write a JUnit XML report with a test case for each file to a `file`")
 
 	return cmd
 }
+
+// describe describes the outcome of a run, the same way whatever the order files were processed in
+func describe(summary formatter.Summary) string {
+	return fmt.Sprintf(
+		"%d file(s) checked, %d failed, %d warning(s)",
+		summary.Files,
+		summary.Failures(),
+		summary.Diagnostics,
+	)
+}
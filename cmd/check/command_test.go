@@ -2,7 +2,10 @@ package check
 
 import (
 	"bytes"
+	"encoding/json"
 	"os"
+	"os/exec"
+	"strings"
 	"testing"
 
 	"github.com/judimator/augurken/log"
@@ -35,7 +38,12 @@ hello world
 	err := command.Execute()
 
 	assert.Error(t, err)
-	assert.EqualValues(t, `an error occurred with file "tmp/file1.feature" : file is not properly formatted`+"\n", buff.String())
+	assert.EqualValues(
+		t,
+		`an error occurred with file "tmp/file1.feature" : file is not properly formatted`+"\n"+
+			"1 file(s) checked, 1 failed, 0 warning(s)\n",
+		buff.String(),
+	)
 	// Clean up
 	_ = os.RemoveAll("tmp/")
 }
@@ -66,7 +74,307 @@ hello world
 	err := command.Execute()
 
 	assert.Error(t, err)
-	assert.EqualValues(t, `an error occurred with file "tmp/file1.feature" : file is not properly formatted`+"\n", buff.String())
+	assert.EqualValues(
+		t,
+		`an error occurred with file "tmp/file1.feature" : file is not properly formatted`+"\n"+
+			"1 file(s) checked, 1 failed, 0 warning(s)\n",
+		buff.String(),
+	)
 	// Clean up
 	_ = os.RemoveAll("tmp/")
 }
+
+func TestCheckInvalidJSONDocString(t *testing.T) {
+	content := []byte(`Feature: test
+
+  Scenario: scenario1
+    Given whatever
+      """json
+      {"key": }
+      """
+`)
+
+	for _, scenario := range []struct {
+		args  []string
+		fails bool
+	}{
+		{[]string{"tmp/file1.feature"}, false},
+		{[]string{"tmp/file1.feature", "--strict"}, true},
+	} {
+		var buff bytes.Buffer
+		logger := log.GetLogger()
+		logger.SetOutput(&buff)
+
+		assert.NoError(t, os.RemoveAll("tmp/"))
+		assert.NoError(t, os.MkdirAll("tmp/", 0o777))
+		assert.NoError(t, os.WriteFile("tmp/file1.feature", content, 0o600))
+
+		command := NewCommand()
+		command.SetArgs(scenario.args)
+		err := command.Execute()
+
+		if scenario.fails {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+		assert.EqualValues(
+			t,
+			"tmp/file1.feature:6:15: invalid character '}' looking for beginning of value\nformatted: tmp/file1.feature\n"+
+				"1 file(s) checked, 0 failed, 1 warning(s)\n",
+			buff.String(),
+		)
+	}
+
+	// Clean up
+	_ = os.RemoveAll("tmp/")
+}
+
+func TestCheckInvalidFileWithDiff(t *testing.T) {
+	var buff, out bytes.Buffer
+	logger := log.GetLogger()
+	logger.SetOutput(&buff)
+
+	content := []byte(`Feature: test
+
+Scenario:            scenario1
+  Given       whatever
+  Then whatever
+`)
+
+	assert.NoError(t, os.RemoveAll("tmp/"))
+	assert.NoError(t, os.MkdirAll("tmp/", 0o777))
+	assert.NoError(t, os.WriteFile("tmp/file1.feature", content, 0o600))
+
+	command := NewCommand()
+	command.SetOut(&out)
+	command.SetArgs([]string{"tmp/file1.feature", "--diff"})
+	err := command.Execute()
+
+	assert.Error(t, err)
+	assert.EqualValues(
+		t,
+		`an error occurred with file "tmp/file1.feature" : file is not properly formatted`+"\n"+
+			"1 file(s) checked, 1 failed, 0 warning(s)\n",
+		buff.String(),
+	)
+	name := diffPrefix(t) + "tmp/file1.feature"
+	assert.Contains(t, out.String(), "diff --git a/"+name+" b/"+name+"\n--- a/"+name+"\n+++ b/"+name+`
+@@ -1,5 +1,5 @@
+ Feature: test
+ 
+-Scenario:            scenario1
+-  Given       whatever
+-  Then whatever
++  Scenario: scenario1
++    Given whatever
++    Then whatever
+`)
+	// Clean up
+	_ = os.RemoveAll("tmp/")
+}
+
+func TestCheckSeveralPaths(t *testing.T) {
+	var buff bytes.Buffer
+	logger := log.GetLogger()
+	logger.SetOutput(&buff)
+
+	content := []byte(`Feature: test
+
+  Scenario: scenario1
+    Given whatever
+`)
+
+	assert.NoError(t, os.RemoveAll("tmp/"))
+	assert.NoError(t, os.MkdirAll("tmp/api", 0o777))
+	assert.NoError(t, os.MkdirAll("tmp/ui", 0o777))
+	assert.NoError(t, os.WriteFile("tmp/api/file1.feature", content, 0o600))
+	assert.NoError(t, os.WriteFile("tmp/ui/file2.feature", content, 0o600))
+
+	command := NewCommand()
+	command.SetArgs([]string{"tmp/**/file2.feature", "tmp/api", "--jobs", "2"})
+	err := command.Execute()
+
+	assert.NoError(t, err)
+	assert.EqualValues(
+		t,
+		"formatted: tmp/api/file1.feature\nformatted: tmp/ui/file2.feature\n2 file(s) checked, 0 failed, 0 warning(s)\n",
+		buff.String(),
+	)
+
+	command = NewCommand()
+	command.SetArgs([]string{"tmp/api", "-"})
+	err = command.Execute()
+
+	assert.Error(t, err)
+
+	// Clean up
+	_ = os.RemoveAll("tmp/")
+}
+
+func TestCheckExclude(t *testing.T) {
+	var buff bytes.Buffer
+	logger := log.GetLogger()
+	logger.SetOutput(&buff)
+
+	content := []byte(`Feature: test
+
+Scenario:            scenario1
+  Given       whatever
+`)
+
+	assert.NoError(t, os.RemoveAll("tmp/"))
+	assert.NoError(t, os.MkdirAll("tmp/vendor", 0o777))
+	assert.NoError(t, os.MkdirAll("tmp/generated", 0o777))
+	assert.NoError(t, os.WriteFile("tmp/.augurkenignore", []byte("generated/\n"), 0o600))
+	assert.NoError(t, os.WriteFile("tmp/vendor/file1.feature", content, 0o600))
+	assert.NoError(t, os.WriteFile("tmp/generated/file2.feature", content, 0o600))
+
+	command := NewCommand()
+	command.SetArgs([]string{"tmp", "--exclude", "vendor"})
+	err := command.Execute()
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, "0 file(s) checked, 0 failed, 0 warning(s)\n", buff.String())
+
+	// Clean up
+	_ = os.RemoveAll("tmp/")
+}
+
+func TestCheckStagedAndChangedSince(t *testing.T) {
+	var buff bytes.Buffer
+	logger := log.GetLogger()
+	logger.SetOutput(&buff)
+
+	for _, scenario := range []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"--staged", "--changed-since", "HEAD"}, "--staged can't be used along with --changed-since\n"},
+		{[]string{"--staged", "-"}, "stdin can't be read along with files listed by git\n"},
+	} {
+		buff.Reset()
+
+		command := NewCommand()
+		command.SetArgs(scenario.args)
+		err := command.Execute()
+
+		assert.Error(t, err)
+		assert.EqualValues(t, scenario.expected, buff.String())
+	}
+}
+
+func TestCheckReports(t *testing.T) {
+	var buff bytes.Buffer
+	logger := log.GetLogger()
+	logger.SetOutput(&buff)
+
+	content := []byte(`Feature: test
+
+Scenario:            scenario1
+  Given       whatever
+`)
+
+	assert.NoError(t, os.RemoveAll("tmp/"))
+	assert.NoError(t, os.MkdirAll("tmp/", 0o777))
+	assert.NoError(t, os.WriteFile("tmp/file1.feature", content, 0o600))
+
+	var out bytes.Buffer
+
+	command := NewCommand()
+	command.SetOut(&out)
+	command.SetArgs([]string{"tmp", "--output", "json"})
+	err := command.Execute()
+
+	assert.Error(t, err)
+	assert.Contains(t, out.String(), `"path": "tmp/file1.feature"`)
+	assert.Contains(t, out.String(), `"status": "unformatted"`)
+
+	command = NewCommand()
+	command.SetArgs([]string{"tmp", "--output", "json", "--output-file", "tmp/report.json"})
+	err = command.Execute()
+
+	assert.Error(t, err)
+
+	b, err := os.ReadFile("tmp/report.json")
+	assert.NoError(t, err)
+	assert.EqualValues(t, out.String(), string(b))
+
+	command = NewCommand()
+	command.SetArgs([]string{"tmp", "--junit", "tmp/report.xml"})
+	err = command.Execute()
+
+	assert.Error(t, err)
+
+	b, err = os.ReadFile("tmp/report.xml")
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `<testcase name="tmp/file1.feature" classname="augurken">`)
+	assert.Contains(t, string(b), `<failure message="file is not properly formatted" type="unformatted">`)
+
+	out.Reset()
+
+	command = NewCommand()
+	command.SetOut(&out)
+	command.SetArgs([]string{"tmp", "--format", "sarif"})
+	err = command.Execute()
+
+	assert.Error(t, err)
+	assert.Contains(t, out.String(), `"version": "2.1.0"`)
+	assert.Contains(t, out.String(), `"uri": "tmp/file1.feature"`)
+
+	out.Reset()
+
+	command = NewCommand()
+	command.SetOut(&out)
+	command.SetArgs([]string{"tmp", "--format", "github"})
+	err = command.Execute()
+
+	assert.Error(t, err)
+	assert.EqualValues(
+		t,
+		"::error file=tmp/file1.feature,line=3,endLine=4,title=augurken unformatted::file is not properly formatted\n",
+		out.String(),
+	)
+
+	out.Reset()
+
+	command = NewCommand()
+	command.SetOut(&out)
+	command.SetArgs([]string{"tmp", "--format", "gitlab", "--output-file", "tmp/gl-code-quality-report.json"})
+	err = command.Execute()
+
+	assert.Error(t, err)
+	assert.Empty(t, out.String())
+
+	b, err = os.ReadFile("tmp/gl-code-quality-report.json")
+	assert.NoError(t, err)
+
+	var issues []map[string]any
+
+	assert.NoError(t, json.Unmarshal(b, &issues))
+	assert.Len(t, issues, 1)
+	assert.Equal(t, "augurken/unformatted", issues[0]["check_name"])
+	assert.Equal(t, map[string]any{"path": "tmp/file1.feature", "lines": map[string]any{"begin": 3.0}}, issues[0]["location"])
+
+	command = NewCommand()
+	command.SetArgs([]string{"tmp", "--output", "whatever"})
+	err = command.Execute()
+
+	assert.EqualError(t, err, `unknown output format "whatever", expected one of text, json, sarif, github, gitlab`)
+
+	// Clean up
+	_ = os.RemoveAll("tmp/")
+}
+
+// diffPrefix returns the working directory relative to the root of its git repository, as it starts the paths
+// in the headers of diffs
+func diffPrefix(t *testing.T) string {
+	t.Helper()
+
+	prefix, err := exec.Command("git", "rev-parse", "--show-prefix").Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(prefix))
+}
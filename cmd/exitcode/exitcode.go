@@ -0,0 +1,44 @@
+package exitcode
+
+import "github.com/judimator/augurken/formatter"
+
+// Codes the program exits with
+const (
+	// OK means all files are properly formatted or were formatted
+	OK = 0
+	// Unformatted means files are not properly formatted, or diagnostics were reported in strict mode
+	Unformatted = 1
+	// ParseError means files are not valid Gherkin features
+	ParseError = 2
+	// Failure means files could not be read or written, or the command line is invalid
+	Failure = 3
+)
+
+// Error is the error returned by a command when files fail, along with the code the program exits with.
+// Other errors returned by a command exit with Failure
+type Error struct {
+	Code int
+	Err  error
+}
+
+func (e Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
+// Of returns the code of the most severe failure of a run
+func Of(summary formatter.Summary, strict bool) int {
+	switch {
+	case summary.IOErrors > 0, summary.OtherErrors > 0:
+		return Failure
+	case summary.ParseErrors > 0:
+		return ParseError
+	case summary.Unformatted > 0, strict && summary.Diagnostics > 0:
+		return Unformatted
+	default:
+		return OK
+	}
+}
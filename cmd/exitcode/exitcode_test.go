@@ -0,0 +1,31 @@
+package exitcode
+
+import (
+	"testing"
+
+	"github.com/judimator/augurken/formatter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOf(t *testing.T) {
+	for name, test := range map[string]struct {
+		summary formatter.Summary
+		strict  bool
+		code    int
+	}{
+		"nothing failed":                   {formatter.Summary{Files: 2, Unchanged: 1, Reformatted: 1}, false, OK},
+		"unformatted files":                {formatter.Summary{Files: 1, Unformatted: 1}, false, Unformatted},
+		"parse errors":                     {formatter.Summary{Files: 1, ParseErrors: 1}, false, ParseError},
+		"IO errors":                        {formatter.Summary{Files: 1, IOErrors: 1}, false, Failure},
+		"paths that could not be expanded": {formatter.Summary{OtherErrors: 1}, false, Failure},
+		"parse errors over unformatted":    {formatter.Summary{Files: 2, Unformatted: 1, ParseErrors: 1}, false, ParseError},
+		"IO errors over parse errors":      {formatter.Summary{Files: 2, ParseErrors: 1, IOErrors: 1}, false, Failure},
+		"IO errors over everything":        {formatter.Summary{Files: 3, Unformatted: 1, ParseErrors: 1, IOErrors: 1, Diagnostics: 1}, true, Failure},
+		"diagnostics":                      {formatter.Summary{Files: 1, Unchanged: 1, Diagnostics: 1}, false, OK},
+		"diagnostics in strict mode":       {formatter.Summary{Files: 1, Unchanged: 1, Diagnostics: 1}, true, Unformatted},
+		"strict mode without diagnostics":  {formatter.Summary{Files: 1, Unchanged: 1}, true, OK},
+		"parse errors over strict mode":    {formatter.Summary{Files: 2, ParseErrors: 1, Diagnostics: 1}, true, ParseError},
+	} {
+		assert.Equal(t, test.code, Of(test.summary, test.strict), name)
+	}
+}
@@ -2,51 +2,181 @@ package format
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
+	"github.com/judimator/augurken/cmd/exitcode"
+	"github.com/judimator/augurken/cmd/internal/shared"
 	"github.com/judimator/augurken/formatter"
 	"github.com/judimator/augurken/log"
+	"github.com/judimator/augurken/report"
 	"github.com/spf13/cobra"
 )
 
+// outputFormats lists the formats of the report of a run
+var outputFormats = []string{report.FormatText, report.FormatJSON}
+
 func NewCommand() *cobra.Command {
-	var indent int
+	var (
+		flags  *shared.Flags
+		dryRun bool
+		patch  string
+		watch  bool
+	)
 	cmd := &cobra.Command{
-		Use:   "format [file or path]",
+		Use:   "format [files, paths or glob patterns, or - to read stdin]",
 		Short: "Format gherkin file(s)",
-		Args:  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		Args:  cobra.OnlyValidArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) != 1 {
-				err := errors.New("please, specify file or folder")
+			args, err := flags.Validate(args)
+			if err != nil {
+				return err
+			}
+
+			stdin := args[0] == shared.StdinPath
+			// A preview only checks files, it never writes them
+			preview := dryRun || patch != ""
+
+			// The report and the formatted feature can't both be written to stdout
+			if flags.Output != report.FormatText && flags.OutputFile == "" && stdin && !preview {
+				err := errors.New("--output-file must be given to write a report along with a feature read from stdin")
 				log.Error(err)
 
 				return err
 			}
 
-			success := true
-			indent, _ := cmd.Flags().GetInt("indent")
-			fileManager := formatter.NewFileManager(indent)
-			result := fileManager.FormatAndReplace(args[0])
+			if watch && (preview || flags.FromGit() || stdin) {
+				err := errors.New("--watch can't be used along with stdin, --dry-run, --patch, --staged or --changed-since")
+				log.Error(err)
 
-			for _, r := range result {
-				if s, ok := r.(string); ok {
-					log.Success(s)
+				return err
+			}
 
-					continue
+			fileManager := flags.FileManager(cmd)
+
+			if watch {
+				return watchFolders(cmd, fileManager, args)
+			}
+
+			var results formatter.Results
+
+			switch {
+			case stdin && preview:
+				results = formatter.Results{fileManager.CheckStream(flags.StdinFilename, cmd.InOrStdin())}
+			case stdin:
+				results = formatter.Results{fileManager.FormatStream(flags.StdinFilename, cmd.InOrStdin(), cmd.OutOrStdout())}
+			case preview:
+				results = fileManager.Check(args...)
+			default:
+				results = fileManager.FormatAndReplace(args...)
+			}
+
+			for _, result := range results {
+				flags.LogDetails(result, !preview && result.Status == formatter.StatusReformatted)
+
+				switch {
+				case preview && result.Status == formatter.StatusUnformatted:
+					log.Success("would reformat: " + result.Path)
+				case result.Err != nil:
+					log.Error(result.Err)
+				case !preview:
+					log.Success("formatted: " + result.Path)
 				}
-				if e, ok := r.(error); ok {
-					log.Error(e)
-					success = false
+			}
+
+			summary := results.Summary()
+			log.Info(describe(preview, summary))
+
+			if err := flags.WriteReport(cmd, results); err != nil {
+				return err
+			}
+
+			if patch != "" {
+				if err := writePatch(patch, results); err != nil {
+					log.Error(err)
+
+					return err
 				}
 			}
 
-			if !success {
-				return errors.New("error occurred while formatting file/folder")
+			// Files that are not properly formatted are only reported by a preview
+			if summary.ParseErrors+summary.IOErrors+summary.OtherErrors > 0 || flags.Strict && summary.Diagnostics > 0 {
+				return exitcode.Error{
+					Code: exitcode.Of(summary, flags.Strict),
+					Err:  errors.New("error occurred while formatting file/folder"),
+				}
 			}
 
 			return nil
 		},
 	}
-	cmd.Flags().IntVarP(&indent, "indent", "i", 2, "set the indentation for Gherkin features (default 2)")
+	flags = shared.AddFlags(
+		cmd,
+		outputFormats,
+		"format the feature files staged in the git index and stage the formatted content",
+	)
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "list the files that would be formatted without writing them")
+	cmd.Flags().StringVar(&patch, "patch", "", "write the changes to a git patch `file` instead of formatting files")
+	cmd.Flags().BoolVar(&watch, "watch", false, "watch folders and format feature files each time they are written")
 
 	return cmd
 }
+
+// watchFolders formats the feature files of folders each time they are written, until the command is interrupted
+func watchFolders(cmd *cobra.Command, fileManager formatter.FileManager, folders []string) error {
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Info("watching " + strings.Join(folders, ", ") + ", press Ctrl+C to stop")
+
+	err := fileManager.Watch(ctx, folders, func(result formatter.Result) {
+		for _, diagnostic := range result.Diagnostics {
+			log.Warning(diagnostic)
+		}
+
+		switch {
+		case result.Err != nil:
+			log.Error(result.Err)
+		case result.Status == formatter.StatusReformatted:
+			log.Success("formatted: " + result.Path)
+		}
+	})
+	if err != nil {
+		log.Error(err)
+	}
+
+	return err
+}
+
+// writePatch writes all changes as a single patch. Results are in path order so the patch is reproducible
+func writePatch(filename string, results formatter.Results) error {
+	var patch strings.Builder
+	for _, result := range results {
+		patch.WriteString(result.Diff())
+	}
+
+	return os.WriteFile(filename, []byte(patch.String()), 0o600)
+}
+
+// describe describes the outcome of a run, the same way whatever the order files were processed in
+func describe(preview bool, summary formatter.Summary) string {
+	if preview {
+		return fmt.Sprintf(
+			"%d file(s) checked, %d would be reformatted, %d failed, %d warning(s)",
+			summary.Files,
+			summary.Unformatted,
+			summary.ParseErrors+summary.IOErrors+summary.OtherErrors,
+			summary.Diagnostics,
+		)
+	}
+
+	return fmt.Sprintf(
+		"%d file(s) processed, %d failed, %d warning(s)",
+		summary.Files,
+		summary.Failures(),
+		summary.Diagnostics,
+	)
+}
@@ -1,9 +1,13 @@
 package format
 
 import (
+	"bytes"
 	"os"
+	"os/exec"
+	"strings"
 	"testing"
 
+	"github.com/judimator/augurken/log"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -92,3 +96,242 @@ hello world
 	// Clean up
 	_ = os.RemoveAll("tmp/")
 }
+
+func TestFormatDryRun(t *testing.T) {
+	var buff bytes.Buffer
+	logger := log.GetLogger()
+	logger.SetOutput(&buff)
+
+	content := []byte(`Feature: test
+
+Scenario:            scenario1
+  Given       whatever
+`)
+	formatted := []byte(`Feature: test
+
+  Scenario: scenario1
+    Given whatever
+`)
+
+	assert.NoError(t, os.RemoveAll("tmp/"))
+	assert.NoError(t, os.MkdirAll("tmp/", 0o777))
+	assert.NoError(t, os.WriteFile("tmp/file1.feature", content, 0o600))
+	assert.NoError(t, os.WriteFile("tmp/file2.feature", formatted, 0o600))
+
+	command := NewCommand()
+	command.SetArgs([]string{"tmp", "--dry-run"})
+	err := command.Execute()
+
+	assert.NoError(t, err)
+	assert.EqualValues(
+		t,
+		"would reformat: tmp/file1.feature\n2 file(s) checked, 1 would be reformatted, 0 failed, 0 warning(s)\n",
+		buff.String(),
+	)
+
+	b, err := os.ReadFile("tmp/file1.feature")
+	assert.NoError(t, err)
+	assert.EqualValues(t, content, b)
+
+	// Clean up
+	_ = os.RemoveAll("tmp/")
+}
+
+func TestFormatPatch(t *testing.T) {
+	content := []byte(`Feature: test
+
+Scenario:            scenario1
+  Given       whatever
+`)
+
+	assert.NoError(t, os.RemoveAll("tmp/"))
+	assert.NoError(t, os.MkdirAll("tmp/test1", 0o777))
+	assert.NoError(t, os.WriteFile("tmp/file1.feature", content, 0o600))
+	assert.NoError(t, os.WriteFile("tmp/test1/file2.feature", content, 0o600))
+
+	command := NewCommand()
+	command.SetArgs([]string{"tmp", "--patch", "tmp/out.diff"})
+	err := command.Execute()
+
+	assert.NoError(t, err)
+
+	b, err := os.ReadFile("tmp/out.diff")
+	// Paths are relative to the root of the repository
+	expected := strings.ReplaceAll(`diff --git a/tmp/file1.feature b/tmp/file1.feature
+--- a/tmp/file1.feature
++++ b/tmp/file1.feature
+@@ -1,4 +1,4 @@
+ Feature: test
+ 
+-Scenario:            scenario1
+-  Given       whatever
++  Scenario: scenario1
++    Given whatever
+diff --git a/tmp/test1/file2.feature b/tmp/test1/file2.feature
+--- a/tmp/test1/file2.feature
++++ b/tmp/test1/file2.feature
+@@ -1,4 +1,4 @@
+ Feature: test
+ 
+-Scenario:            scenario1
+-  Given       whatever
++  Scenario: scenario1
++    Given whatever
+`, "/tmp/", "/"+diffPrefix(t)+"tmp/")
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, expected, string(b))
+
+	b, err = os.ReadFile("tmp/file1.feature")
+	assert.NoError(t, err)
+	assert.EqualValues(t, content, b)
+
+	// Clean up
+	_ = os.RemoveAll("tmp/")
+}
+
+func TestFormatStdin(t *testing.T) {
+	var out bytes.Buffer
+
+	command := NewCommand()
+	command.SetIn(bytes.NewBufferString(`Feature: test
+
+Scenario:            scenario1
+  Given       whatever
+`))
+	command.SetOut(&out)
+	command.SetArgs([]string{"-", "-i", "4", "--stdin-filename", "features/login.feature"})
+	err := command.Execute()
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, `Feature: test
+
+    Scenario: scenario1
+        Given whatever
+`, out.String())
+}
+
+func TestFormatWithConfigFile(t *testing.T) {
+	content := []byte(`Feature: test
+
+Scenario:            scenario1
+  Given       whatever
+`)
+
+	for _, scenario := range []struct {
+		args     []string
+		expected string
+	}{
+		{
+			[]string{"tmp"},
+			`Feature: test
+
+    Scenario: scenario1
+        Given whatever
+`,
+		},
+		{
+			[]string{"tmp", "-i", "3"},
+			`Feature: test
+
+   Scenario: scenario1
+      Given whatever
+`,
+		},
+	} {
+		assert.NoError(t, os.RemoveAll("tmp/"))
+		assert.NoError(t, os.MkdirAll("tmp/", 0o777))
+		assert.NoError(t, os.WriteFile("tmp/.augurken.yaml", []byte("indent: 4\n"), 0o600))
+		assert.NoError(t, os.WriteFile("tmp/file1.feature", content, 0o600))
+
+		command := NewCommand()
+		command.SetArgs(scenario.args)
+		err := command.Execute()
+
+		assert.NoError(t, err)
+
+		b, err := os.ReadFile("tmp/file1.feature")
+		assert.NoError(t, err)
+		assert.EqualValues(t, scenario.expected, string(b))
+	}
+
+	// Clean up
+	_ = os.RemoveAll("tmp/")
+}
+
+func TestFormatReport(t *testing.T) {
+	assert.NoError(t, os.RemoveAll("tmp/"))
+	assert.NoError(t, os.MkdirAll("tmp/", 0o777))
+	assert.NoError(t, os.WriteFile("tmp/file1.feature", []byte("Feature: test\n\nScenario: scenario1\n"), 0o600))
+
+	// --format is an alias of --output
+	for _, flag := range []string{"--output", "--format"} {
+		var out bytes.Buffer
+
+		command := NewCommand()
+		command.SetOut(&out)
+		command.SetArgs([]string{"tmp", "--dry-run", flag, "json"})
+
+		assert.NoError(t, command.Execute(), flag)
+		assert.Contains(t, out.String(), `"status": "unformatted"`, flag)
+	}
+
+	assert.Contains(t, NewCommand().UsageString(), "--format format")
+
+	// Clean up
+	_ = os.RemoveAll("tmp/")
+}
+
+func TestFormatConversion(t *testing.T) {
+	var buff bytes.Buffer
+
+	logger := log.GetLogger()
+	logger.SetOutput(&buff)
+
+	assert.NoError(t, os.RemoveAll("tmp/"))
+	assert.NoError(t, os.MkdirAll("tmp/", 0o777))
+	assert.NoError(t, os.WriteFile("tmp/file1.feature", []byte("Feature: caf\xe9\n\nScenario: scenario1\n"), 0o600))
+
+	// The conversion is only logged as done when the file is written
+	for _, step := range []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"--dry-run"}, ""},
+		{[]string{"--dry-run", "--verbose"}, "tmp/file1.feature: content would be converted from latin1 to UTF-8\n"},
+		{nil, "tmp/file1.feature: content converted from latin1 to UTF-8\n"},
+	} {
+		buff.Reset()
+
+		command := NewCommand()
+		command.SetArgs(append([]string{"tmp", "--encoding", "latin1"}, step.args...))
+
+		assert.NoError(t, command.Execute(), step.args)
+
+		if step.expected == "" {
+			assert.NotContains(t, buff.String(), "converted", step.args)
+		} else {
+			assert.Contains(t, buff.String(), step.expected, step.args)
+		}
+	}
+
+	b, err := os.ReadFile("tmp/file1.feature")
+	assert.NoError(t, err)
+	assert.EqualValues(t, "Feature: café\n\n  Scenario: scenario1\n", b)
+
+	// Clean up
+	_ = os.RemoveAll("tmp/")
+}
+
+// diffPrefix returns the working directory relative to the root of its git repository, as it starts the paths
+// in the headers of diffs
+func diffPrefix(t *testing.T) string {
+	t.Helper()
+
+	prefix, err := exec.Command("git", "rev-parse", "--show-prefix").Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(prefix))
+}
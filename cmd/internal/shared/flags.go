@@ -0,0 +1,218 @@
+// Package shared holds the flags and the output shared by the check and format commands
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/judimator/augurken/formatter"
+	"github.com/judimator/augurken/log"
+	"github.com/judimator/augurken/report"
+	"github.com/spf13/cobra"
+)
+
+// StdinPath is the path to give to read a feature from stdin
+const StdinPath = "-"
+
+// Flags holds the values of the flags shared by the check and format commands
+type Flags struct {
+	Indent           int
+	Strict           bool
+	StdinFilename    string
+	Excludes         []string
+	RespectGitIgnore bool
+	Jobs             int
+	UseCache         bool
+	CacheLocation    string
+	ChangedSince     string
+	Staged           bool
+	Encoding         string
+	MinConfidence    int
+	PreserveEncoding bool
+	EOL              string
+	BOM              string
+	FinalNewline     string
+	Verbose          bool
+	Output           string
+	OutputFile       string
+	// outputFormats lists the formats of the report of a run supported by the command
+	outputFormats []string
+}
+
+// overrides maps the flags overriding a setting of configuration files to the setting
+var overrides = map[string]string{
+	"indent":            formatter.SettingIndent,
+	"encoding":          formatter.SettingEncodingCharset,
+	"min-confidence":    formatter.SettingEncodingConfidence,
+	"preserve-encoding": formatter.SettingEncodingPreserve,
+	"eol":               formatter.SettingEOL,
+	"bom":               formatter.SettingBOM,
+	"final-newline":     formatter.SettingFinalNewline,
+}
+
+// AddFlags registers the shared flags of a command. The report of a run can be written in the given formats,
+// stagedUsage describes what the command does with the files staged in the git index
+func AddFlags(cmd *cobra.Command, outputFormats []string, stagedUsage string) *Flags {
+	f := &Flags{outputFormats: outputFormats}
+
+	cmd.Flags().IntVarP(&f.Indent, "indent", "i", 2, "set the indentation for Gherkin features (default 2)")
+	cmd.Flags().BoolVar(
+		&f.Strict,
+		"strict",
+		false,
+		"fail when a diagnostic is reported, like invalid JSON in a doc string",
+	)
+	cmd.Flags().StringArrayVar(
+		&f.Excludes,
+		"exclude",
+		nil,
+		"skip files matching a `pattern` with the gitignore syntax when walking folders (can be repeated)",
+	)
+	cmd.Flags().BoolVar(&f.RespectGitIgnore, "respect-gitignore", false, "skip files ignored by git when walking folders")
+	cmd.Flags().IntVarP(&f.Jobs, "jobs", "j", runtime.GOMAXPROCS(0), "set the number of files processed in parallel")
+	cmd.Flags().BoolVar(&f.UseCache, "cache", false, "skip the files known to be properly formatted by a previous run")
+	cmd.Flags().StringVar(
+		&f.CacheLocation,
+		"cache-location",
+		formatter.DefaultCacheFile,
+		"set the `file` where the files known to be properly formatted are recorded",
+	)
+	cmd.Flags().StringVar(
+		&f.ChangedSince,
+		"changed-since",
+		"",
+		"only process the feature files changed since a git `ref`, untracked files included",
+	)
+	cmd.Flags().BoolVar(&f.Staged, "staged", false, stagedUsage)
+	cmd.Flags().StringVar(&f.Encoding, "encoding", "", "set the `charset` of the files rather than detecting it")
+	cmd.Flags().IntVar(
+		&f.MinConfidence,
+		"min-confidence",
+		0,
+		"refuse the files whose charset is detected with a confidence lower than a `percentage`",
+	)
+	cmd.Flags().BoolVar(
+		&f.PreserveEncoding,
+		"preserve-encoding",
+		false,
+		"keep the charset of the files rather than converting them to UTF-8",
+	)
+	cmd.Flags().StringVar(
+		&f.EOL,
+		"eol",
+		formatter.EOLPreserve,
+		"set the line endings `policy`: lf, crlf, native or preserve the first line ending found",
+	)
+	cmd.Flags().StringVar(&f.BOM, "bom", formatter.BOMKeep, "set the BOM `policy`: keep, strip or add")
+	cmd.Flags().StringVar(
+		&f.FinalNewline,
+		"final-newline",
+		formatter.FinalNewlineAdd,
+		"set the final newline `policy`: keep, strip or add",
+	)
+	cmd.Flags().StringVar(
+		&f.Output,
+		"output",
+		report.FormatText,
+		"set the `format` of the report of the run: "+strings.Join(outputFormats, ", "),
+	)
+	// --format is an alias of --output, both flags set the same variable
+	cmd.Flags().StringVar(&f.Output, "format", report.FormatText, "set the `format` of the report, alias of --output")
+	cmd.Flags().StringVar(&f.OutputFile, "output-file", "", "write the report to a `file` rather than to stdout")
+	cmd.Flags().BoolVar(&f.Verbose, "verbose", false, "print the charset detected in each file")
+	cmd.Flags().StringVar(
+		&f.StdinFilename,
+		"stdin-filename",
+		"<stdin>",
+		"set the file name used in messages when reading stdin",
+	)
+
+	return f
+}
+
+// FromGit tells whether the files to process are listed by git
+func (f *Flags) FromGit() bool {
+	return f.Staged || f.ChangedSince != ""
+}
+
+// Validate checks the shared flags along with the files, paths or glob patterns given, and returns them.
+// All files listed by git are processed when none is given. Errors are logged
+func (f *Flags) Validate(args []string) ([]string, error) {
+	var err error
+
+	// Files listed by git are restricted to the given paths, all files are listed when none is given
+	if f.FromGit() && len(args) == 0 {
+		args = []string{"."}
+	}
+
+	switch {
+	case f.Staged && f.ChangedSince != "":
+		err = errors.New("--staged can't be used along with --changed-since")
+	case len(args) == 0:
+		err = errors.New("please, specify file or folder")
+	case f.FromGit() && slices.Contains(args, StdinPath):
+		err = errors.New("stdin can't be read along with files listed by git")
+	case len(args) > 1 && slices.Contains(args, StdinPath):
+		err = errors.New("stdin can't be read along with other files or folders")
+	case f.Jobs < 1:
+		err = errors.New("jobs must be at least 1")
+	case !slices.Contains(f.outputFormats, f.Output):
+		err = fmt.Errorf(
+			`unknown output format "%s", expected one of %s`,
+			f.Output,
+			strings.Join(f.outputFormats, ", "),
+		)
+	}
+
+	if err != nil {
+		log.Error(err)
+
+		return args, err
+	}
+
+	return args, nil
+}
+
+// FileManager returns the file manager processing files as set by the flags. Settings given on the command line
+// take precedence over configuration files
+func (f *Flags) FileManager(cmd *cobra.Command) formatter.FileManager {
+	var settings []string
+
+	for flag, setting := range overrides {
+		if cmd.Flags().Changed(flag) {
+			settings = append(settings, setting)
+		}
+	}
+
+	options := formatter.DefaultOptions()
+	options.Indent = f.Indent
+	options.Encoding = f.Encoding
+	options.MinConfidence = f.MinConfidence
+	options.PreserveEncoding = f.PreserveEncoding
+	options.EOL = f.EOL
+	options.BOM = f.BOM
+	options.FinalNewline = f.FinalNewline
+
+	fileManager := formatter.NewFileManager(f.Indent).
+		WithOptions(options).
+		WithConfigFiles(settings...).
+		WithExcludes(f.Excludes...).
+		WithJobs(f.Jobs)
+	if f.RespectGitIgnore {
+		fileManager = fileManager.WithGitIgnore()
+	}
+	if f.UseCache {
+		fileManager = fileManager.WithCache(f.CacheLocation)
+	}
+	if f.Staged {
+		fileManager = fileManager.WithStaged()
+	}
+	if f.ChangedSince != "" {
+		fileManager = fileManager.WithChangedSince(f.ChangedSince)
+	}
+
+	return fileManager
+}
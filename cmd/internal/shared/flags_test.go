@@ -0,0 +1,42 @@
+package shared
+
+import (
+	"testing"
+
+	"github.com/judimator/augurken/report"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	for _, test := range []struct {
+		flags    []string
+		args     []string
+		expected []string
+		err      string
+	}{
+		{nil, []string{"features"}, []string{"features"}, ""},
+		{[]string{"--staged"}, nil, []string{"."}, ""},
+		{[]string{"--staged", "--changed-since", "HEAD"}, nil, []string{"."}, "--staged can't be used along with --changed-since"},
+		{nil, nil, nil, "please, specify file or folder"},
+		{[]string{"--changed-since", "HEAD"}, []string{"-"}, []string{"-"}, "stdin can't be read along with files listed by git"},
+		{nil, []string{"-", "features"}, []string{"-", "features"}, "stdin can't be read along with other files or folders"},
+		{[]string{"-j", "0"}, []string{"features"}, []string{"features"}, "jobs must be at least 1"},
+		{[]string{"--format", "sarif"}, []string{"features"}, []string{"features"}, `unknown output format "sarif", expected one of text, json`},
+	} {
+		cmd := &cobra.Command{}
+		flags := AddFlags(cmd, []string{report.FormatText, report.FormatJSON}, "")
+
+		assert.NoError(t, cmd.ParseFlags(test.flags))
+
+		args, err := flags.Validate(test.args)
+
+		assert.Equal(t, test.expected, args, test.flags)
+
+		if test.err == "" {
+			assert.NoError(t, err, test.flags)
+		} else {
+			assert.EqualError(t, err, test.err, test.flags)
+		}
+	}
+}
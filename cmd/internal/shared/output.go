@@ -0,0 +1,64 @@
+package shared
+
+import (
+	"fmt"
+
+	"github.com/judimator/augurken/formatter"
+	"github.com/judimator/augurken/log"
+	"github.com/judimator/augurken/report"
+	"github.com/spf13/cobra"
+)
+
+// LogDetails logs what was detected in the content of a file, in verbose mode, along with its diagnostics.
+// The conversion of a content to UTF-8 is logged when the formatted content was written, and only in verbose
+// mode otherwise
+func (f *Flags) LogDetails(result formatter.Result, written bool) {
+	switch {
+	case !f.Verbose || result.Encoding == "":
+	case result.EncodingGiven:
+		log.Info(fmt.Sprintf("%s: charset %s given", result.Path, result.Encoding))
+	default:
+		log.Info(fmt.Sprintf(
+			"%s: charset %s detected with a confidence of %d%%",
+			result.Path,
+			result.Encoding,
+			result.Confidence,
+		))
+	}
+
+	switch {
+	case !result.Converted:
+	case written:
+		log.Info(fmt.Sprintf("%s: content converted from %s to UTF-8", result.Path, result.Encoding))
+	case f.Verbose:
+		log.Info(fmt.Sprintf("%s: content would be converted from %s to UTF-8", result.Path, result.Encoding))
+	}
+
+	for _, diagnostic := range result.Diagnostics {
+		log.Warning(diagnostic)
+	}
+}
+
+// WriteReport writes the report of the results set by the output flags, to a file or to stdout when no file
+// is given. Nothing is written with the text format, the results are only logged. Errors are logged
+func (f *Flags) WriteReport(cmd *cobra.Command, results formatter.Results) error {
+	if f.Output == report.FormatText {
+		return nil
+	}
+
+	// The report written to stdout must not be followed by the usage when the run fails
+	cmd.SilenceUsage = true
+
+	var err error
+	if f.OutputFile == "" {
+		err = report.Write(cmd.OutOrStdout(), f.Output, results)
+	} else {
+		err = report.WriteFile(f.OutputFile, f.Output, results)
+	}
+
+	if err != nil {
+		log.Error(err)
+	}
+
+	return err
+}
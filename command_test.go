@@ -12,12 +12,14 @@ import (
 
 func Test_Main(t *testing.T) {
 	os.Args = []string{"augurken", "--help"}
-	exitFn = func(code int) { assert.Equal(t, 0, code) }
+	exitCode := recordExitCode()
 
 	r, w, _ := os.Pipe()
 	os.Stdout = w
 
 	main()
+	assert.Equal(t, 0, *exitCode)
+
 	_ = w.Close()
 	buf := new(bytes.Buffer)
 
@@ -31,12 +33,14 @@ func Test_Main(t *testing.T) {
 
 func Test_MainWithoutCommands(t *testing.T) {
 	os.Args = []string{"augurken"}
-	exitFn = func(code int) { assert.Equal(t, 0, code) }
+	exitCode := recordExitCode()
 
 	r, w, _ := os.Pipe()
 	os.Stdout = w
 
 	main()
+	assert.Equal(t, 0, *exitCode)
+
 	buf := new(bytes.Buffer)
 	_ = r.SetReadDeadline(time.Now().Add(time.Second))
 	_, _ = io.Copy(buf, r)
@@ -48,12 +52,14 @@ func Test_MainWithoutCommands(t *testing.T) {
 
 func Test_MainUnknownSubcommand(t *testing.T) {
 	os.Args = []string{"", "foobar"}
-	exitFn = func(code int) { assert.Equal(t, 1, code) }
+	exitCode := recordExitCode()
 
 	r, w, _ := os.Pipe()
 	os.Stderr = w
 
 	main()
+	assert.Equal(t, 3, *exitCode)
+
 	_ = w.Close()
 	buf := new(bytes.Buffer)
 
@@ -83,12 +89,14 @@ hello world
 
 	// Set up the command
 	os.Args = []string{"augurken", "check", "tmp"}
-	exitFn = func(code int) { assert.Equal(t, 1, code) }
+	exitCode := recordExitCode()
 
 	r, w, _ := os.Pipe()
 	os.Stderr = w
 
 	main()
+	assert.Equal(t, 1, *exitCode)
+
 	_ = w.Close()
 	buf := new(bytes.Buffer)
 
@@ -100,3 +108,76 @@ hello world
 	// Clean up
 	_ = os.RemoveAll("tmp/")
 }
+
+func Test_MainCheckParseError(t *testing.T) {
+	assert.NoError(t, os.RemoveAll("tmp/"))
+	assert.NoError(t, os.MkdirAll("tmp/", 0o777))
+	assert.NoError(t, os.WriteFile("tmp/file1.feature", []byte("whatever\n"), 0o600))
+
+	os.Args = []string{"augurken", "check", "tmp"}
+	exitCode := recordExitCode()
+
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	main()
+	assert.Equal(t, 2, *exitCode)
+
+	_ = w.Close()
+	_ = r.Close()
+
+	os.Args = []string{"augurken", "check", "tmp/missing.feature"}
+	exitCode = recordExitCode()
+
+	r, w, _ = os.Pipe()
+	os.Stderr = w
+
+	main()
+	assert.Equal(t, 3, *exitCode)
+
+	_ = w.Close()
+	_ = r.Close()
+
+	// Clean up
+	_ = os.RemoveAll("tmp/")
+}
+
+func Test_MainCheckUnformatted(t *testing.T) {
+	assert.NoError(t, os.RemoveAll("tmp/"))
+	assert.NoError(t, os.MkdirAll("tmp/", 0o777))
+	assert.NoError(t, os.WriteFile("tmp/file1.feature", []byte("Feature: test\n\nScenario: scenario1\n"), 0o600))
+
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	// The file is reported until it is formatted
+	for _, step := range []struct {
+		args []string
+		code int
+	}{
+		{[]string{"augurken", "check", "tmp/file1.feature"}, 1},
+		{[]string{"augurken", "format", "tmp/file1.feature"}, 0},
+		{[]string{"augurken", "check", "tmp/file1.feature"}, 0},
+	} {
+		os.Args = step.args
+		exitCode := recordExitCode()
+
+		main()
+		assert.Equal(t, step.code, *exitCode, step.args)
+	}
+
+	_ = w.Close()
+	_ = r.Close()
+
+	// Clean up
+	_ = os.RemoveAll("tmp/")
+}
+
+// recordExitCode replaces the exit function with one recording the code the program exits with.
+// The code is -1 until the program exits
+func recordExitCode() *int {
+	code := -1
+	exitFn = func(c int) { code = c }
+
+	return &code
+}
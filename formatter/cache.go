@@ -0,0 +1,164 @@
+package formatter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/judimator/augurken/meta"
+)
+
+// DefaultCacheFile is the file where the cache is stored when no other file is given
+const DefaultCacheFile = ".augurken-cache"
+
+// cacheContent is the content of a cache file
+type cacheContent struct {
+	Version string `json:"version"`
+	// Files maps the absolute path of each file known to be properly formatted to its entry
+	Files map[string]cacheEntry `json:"files"`
+}
+
+// cacheEntry records a file known to be properly formatted for a key, along with what was detected in its
+// content so a file skipped reports the same as a file formatted
+type cacheEntry struct {
+	Key           string `json:"key"`
+	Encoding      string `json:"encoding,omitempty"`
+	Confidence    int    `json:"confidence,omitempty"`
+	EncodingGiven bool   `json:"encodingGiven,omitempty"`
+	Converted     bool   `json:"converted,omitempty"`
+	EOL           string `json:"eol,omitempty"`
+	BOM           bool   `json:"bom,omitempty"`
+}
+
+// apply fills a result with what was detected in the content of the file
+func (e cacheEntry) apply(result Result) Result {
+	result.Encoding = e.Encoding
+	result.Confidence = e.Confidence
+	result.EncodingGiven = e.EncodingGiven
+	result.Converted = e.Converted
+	result.EOL = e.EOL
+	result.BOM = e.BOM
+
+	return result
+}
+
+// cache records the files known to be properly formatted, so they are not parsed and formatted again.
+// A file is only known to be formatted for a content, options and version of augurken: when any of them
+// changes, its key changes. A nil cache records nothing
+type cache struct {
+	mu      sync.Mutex
+	file    string
+	content cacheContent
+	changed bool
+}
+
+// loadCache reads a cache file. A cache file that is missing, can't be read or was written by another version
+// of augurken is started over
+func loadCache(file string) *cache {
+	c := &cache{file: file, content: cacheContent{Version: meta.Version(), Files: map[string]cacheEntry{}}}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return c
+	}
+
+	var content cacheContent
+	if err := json.Unmarshal(b, &content); err != nil || content.Version != meta.Version() || content.Files == nil {
+		return c
+	}
+
+	c.content = content
+
+	return c
+}
+
+// key identifies a content formatted with some options by the running version of augurken
+func (c *cache) key(content []byte, options Options) string {
+	if c == nil {
+		return ""
+	}
+
+	// Maps are marshalled with sorted keys, so the same options always give the same key
+	o, _ := json.Marshal(options)
+
+	h := sha256.New()
+	h.Write([]byte(meta.Version()))
+	h.Write([]byte{0})
+	h.Write(o)
+	h.Write([]byte{0})
+	h.Write(content)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// lookup returns the entry of a file when it is known to be properly formatted for a key
+func (c *cache) lookup(file string, key string) (cacheEntry, bool) {
+	if c == nil {
+		return cacheEntry{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.content.Files[cachePath(file)]
+
+	return entry, ok && entry.Key == key
+}
+
+// store records a file as properly formatted for a key, along with what was detected in its content
+func (c *cache) store(file string, key string, result Result) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := cacheEntry{
+		Key:           key,
+		Encoding:      result.Encoding,
+		Confidence:    result.Confidence,
+		EncodingGiven: result.EncodingGiven,
+		Converted:     result.Converted,
+		EOL:           result.EOL,
+		BOM:           result.BOM,
+	}
+
+	path := cachePath(file)
+	if c.content.Files[path] != entry {
+		c.content.Files[path] = entry
+		c.changed = true
+	}
+}
+
+// save writes the cache file when files were recorded since it was loaded
+func (c *cache) save() error {
+	if c == nil || !c.changed {
+		return nil
+	}
+
+	b, err := json.Marshal(c.content)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(c.file); errors.Is(err, fs.ErrNotExist) {
+		return os.WriteFile(c.file, b, 0o600)
+	}
+
+	return writeFileAtomically(c.file, b)
+}
+
+// cachePath returns the absolute path of a file, so a file is found whatever the working directory is
+func cachePath(file string) string {
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+
+	return file
+}
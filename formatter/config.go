@@ -0,0 +1,253 @@
+package formatter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/spf13/viper"
+)
+
+// Settings that can be read from a configuration file. The same names are used to tell which
+// settings were given on the command line and must not be replaced by configuration files
+const (
+	SettingIndent              = "indent"
+	SettingInclude             = "include"
+	SettingExclude             = "exclude"
+	SettingDocStringFormatters = "docstring.formatters"
+	SettingDocStringDetectJSON = "docstring.detect-json"
+	SettingTableCompactJSON    = "table.compact-json"
+	SettingEncodingCharset     = "encoding.charset"
+	SettingEncodingConfidence  = "encoding.min-confidence"
+	SettingEncodingPreserve    = "encoding.preserve"
+	SettingEOL                 = "eol"
+	SettingBOM                 = "bom"
+	SettingFinalNewline        = "final-newline"
+)
+
+// viperKeyDelimiter separates the nested keys of configuration files. The default delimiter, a dot,
+// would split the media types used as keys of the doc string formatters, like application/vnd.api+json
+const viperKeyDelimiter = "::"
+
+// configFileNames lists the project configuration files looked up in each folder, from the folder
+// of a formatted file up to the filesystem root. The first file found wins
+var configFileNames = []string{".augurken.yaml", ".augurken.yml", ".augurken.toml"}
+
+// Config holds the settings applying to a feature file
+type Config struct {
+	Options
+	// Include lists glob patterns of the files to format when walking folders, relative to the configuration
+	// file folder. All feature files are formatted when it is empty
+	Include []string
+	// Exclude lists glob patterns of the files to skip when walking folders, relative to the configuration
+	// file folder
+	Exclude []string
+	// File is the configuration file the settings come from, it is empty when no file was found
+	File string
+}
+
+// isExcluded tells whether a file found when walking a folder must be skipped
+func (c Config) isExcluded(file string) bool {
+	if c.File == "" {
+		return false
+	}
+
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(filepath.Dir(c.File), abs)
+	if err != nil {
+		return false
+	}
+
+	rel = filepath.ToSlash(rel)
+
+	if len(c.Include) > 0 && !matchAny(c.Include, rel) {
+		return true
+	}
+
+	return matchAny(c.Exclude, rel)
+}
+
+func matchAny(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := doublestar.Match(pattern, path); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// configResolver finds and loads the configuration file applying to each file. Lookups are cached
+// since all files of a folder share the same configuration file
+type configResolver struct {
+	overrides []string
+	mu        sync.Mutex
+	files     map[string]string
+	settings  map[string]*viper.Viper
+}
+
+func newConfigResolver(overrides []string) *configResolver {
+	return &configResolver{
+		overrides: overrides,
+		files:     map[string]string{},
+		settings:  map[string]*viper.Viper{},
+	}
+}
+
+// resolve applies the settings of the configuration file found for a file on top of the given options
+func (c *configResolver) resolve(file string, options Options) (Config, error) {
+	config := Config{Options: options}
+
+	configFile, err := c.find(file)
+	if err != nil || configFile == "" {
+		return config, err
+	}
+
+	v, err := c.load(configFile)
+	if err != nil {
+		return config, fmt.Errorf("invalid configuration file %s: %w", configFile, err)
+	}
+
+	config.File = configFile
+	config.Include = v.GetStringSlice(viperKey(SettingInclude))
+	config.Exclude = v.GetStringSlice(viperKey(SettingExclude))
+
+	if c.isSet(v, SettingIndent) {
+		config.Indent = v.GetInt(viperKey(SettingIndent))
+	}
+
+	if c.isSet(v, SettingDocStringFormatters) {
+		config.DocStringFormatters = v.GetStringMapString(viperKey(SettingDocStringFormatters))
+	}
+
+	if c.isSet(v, SettingDocStringDetectJSON) {
+		config.DetectJSON = v.GetBool(viperKey(SettingDocStringDetectJSON))
+	}
+
+	if c.isSet(v, SettingTableCompactJSON) {
+		config.CompactTableJSON = v.GetBool(viperKey(SettingTableCompactJSON))
+	}
+
+	if c.isSet(v, SettingEncodingCharset) {
+		config.Encoding = v.GetString(viperKey(SettingEncodingCharset))
+	}
+
+	if c.isSet(v, SettingEncodingConfidence) {
+		config.MinConfidence = v.GetInt(viperKey(SettingEncodingConfidence))
+	}
+
+	if c.isSet(v, SettingEncodingPreserve) {
+		config.PreserveEncoding = v.GetBool(viperKey(SettingEncodingPreserve))
+	}
+
+	if c.isSet(v, SettingEOL) {
+		config.EOL = v.GetString(viperKey(SettingEOL))
+	}
+
+	if c.isSet(v, SettingBOM) {
+		config.BOM = v.GetString(viperKey(SettingBOM))
+	}
+
+	if c.isSet(v, SettingFinalNewline) {
+		config.FinalNewline = v.GetString(viperKey(SettingFinalNewline))
+	}
+
+	if err := config.validate(); err != nil {
+		return config, fmt.Errorf("invalid configuration file %s: %w", configFile, err)
+	}
+
+	return config, nil
+}
+
+// isSet tells whether a setting comes from the configuration file and is not overridden
+func (c *configResolver) isSet(v *viper.Viper, setting string) bool {
+	return v.IsSet(viperKey(setting)) && !slices.Contains(c.overrides, setting)
+}
+
+// viperKey returns the key of a setting in configuration files read with viperKeyDelimiter
+func viperKey(setting string) string {
+	return strings.ReplaceAll(setting, ".", viperKeyDelimiter)
+}
+
+// find walks up from the folder of a file to find the closest configuration file
+func (c *configResolver) find(file string) (string, error) {
+	dir, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var (
+		visited    []string
+		configFile string
+	)
+
+	for {
+		if found, ok := c.files[dir]; ok {
+			configFile = found
+
+			break
+		}
+
+		visited = append(visited, dir)
+
+		if configFile = findConfigFile(dir); configFile != "" {
+			break
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+
+		dir = parent
+	}
+
+	for _, v := range visited {
+		c.files[v] = configFile
+	}
+
+	return configFile, nil
+}
+
+// load reads a configuration file once
+func (c *configResolver) load(configFile string) (*viper.Viper, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if v, ok := c.settings[configFile]; ok {
+		return v, nil
+	}
+
+	v := viper.NewWithOptions(viper.KeyDelimiter(viperKeyDelimiter))
+	v.SetConfigFile(configFile)
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	c.settings[configFile] = v
+
+	return v, nil
+}
+
+func findConfigFile(dir string) string {
+	for _, name := range configFileNames {
+		file := filepath.Join(dir, name)
+		if fi, err := os.Stat(file); err == nil && fi.Mode().IsRegular() {
+			return file
+		}
+	}
+
+	return ""
+}
@@ -1,6 +1,13 @@
 package formatter
 
-import "bytes"
+import (
+	"bytes"
+	"runtime"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/encoding/unicode/utf32"
+)
 
 // eolType represents a new line according to the underlying OS system (Linux, Windows, MacOSX)
 type eolType string
@@ -12,6 +19,22 @@ const (
 	crlf  eolType = "\r\n"
 )
 
+// byteOrderMark is a BOM along with the encoding of the content it starts. The encoding is nil for UTF-8
+type byteOrderMark struct {
+	name     string
+	bom      []byte
+	encoding encoding.Encoding
+}
+
+// byteOrderMarks lists the BOMs detected. UTF-32 BOMs come first since the UTF-32LE BOM starts with the UTF-16LE one
+var byteOrderMarks = []byteOrderMark{
+	{"UTF-32LE", []byte{'\xff', '\xfe', '\x00', '\x00'}, utf32.UTF32(utf32.LittleEndian, utf32.IgnoreBOM)},
+	{"UTF-32BE", []byte{'\x00', '\x00', '\xfe', '\xff'}, utf32.UTF32(utf32.BigEndian, utf32.IgnoreBOM)},
+	{"UTF-8", []byte{'\xef', '\xbb', '\xbf'}, nil},
+	{"UTF-16LE", []byte{'\xff', '\xfe'}, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)},
+	{"UTF-16BE", []byte{'\xfe', '\xff'}, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)},
+}
+
 // ContentHelper adapts a content for the gherkin parser
 // to fix everything that is not taking into account in the parser.
 // The transformer records all settings, remove/replace everything
@@ -19,80 +42,258 @@ const (
 // to be restored in the original content
 type ContentHelper struct {
 	eol eolType
-	bom []byte
+	bom byteOrderMark
+	// policy is the line ending policy applied by Restore, line endings are preserved when it is empty
+	policy string
+	// bomPolicy is the BOM policy applied by Restore, the BOM is kept when it is empty
+	bomPolicy string
+	// finalNewlinePolicy is the final newline policy applied by Restore, a final newline is added when it is empty
+	finalNewlinePolicy string
+	// finalNewline tells whether the content ends with a line ending
+	finalNewline bool
+	// mixedLine and mixedColumn locate the first line ending differing from the first one found
+	mixedLine   int
+	mixedColumn int
+}
+
+// SetEOLPolicy sets the line ending policy applied when restoring a content: lf, crlf, native or preserve
+func (c *ContentHelper) SetEOLPolicy(policy string) {
+	c.policy = policy
+}
+
+// SetBOMPolicy sets the BOM policy applied when restoring a content: keep, strip or add
+func (c *ContentHelper) SetBOMPolicy(policy string) {
+	c.bomPolicy = policy
+}
+
+// SetFinalNewlinePolicy sets the final newline policy applied when restoring a content: keep, strip or add
+func (c *ContentHelper) SetFinalNewlinePolicy(policy string) {
+	c.finalNewlinePolicy = policy
 }
 
 // DetectSettings stores all settings specifics to the content being processed
 func (c *ContentHelper) DetectSettings(content []byte) {
-	c.detectEOL(content)
 	c.detectBom(content)
+	c.detectEOL(c.decode(c.removeBom(content)))
 }
 
 // Prepare removes/replaces all settings int the content
-// that would not be handle properly by the gherkin parser
+// that would not be handle properly by the gherkin parser.
+// Contents starting with a UTF-16 or UTF-32 BOM are decoded to UTF-8
 func (c *ContentHelper) Prepare(content []byte) []byte {
-	return c.replaceEOLWithLF(c.removeBom(content))
+	return c.replaceEOLWithLF(c.decode(c.removeBom(content)))
 }
 
 // Restore used recorded settings to restore all settings from the original content
-// that need to be preserved
+// that need to be preserved. Contents are encoded back in the encoding given by their BOM
 func (c *ContentHelper) Restore(content []byte) []byte {
-	return c.addBom(c.replaceLFWithEOl(content))
+	return c.addBom(c.encode(c.replaceLFWithEOl(c.applyFinalNewline(content))))
+}
+
+// EOL returns the name of the detected line separator: lf, crlf, cr or an empty string for none
+func (c *ContentHelper) EOL() string {
+	switch c.eol {
+	case lf:
+		return "lf"
+	case crlf:
+		return "crlf"
+	case cr:
+		return "cr"
+	default:
+		return ""
+	}
+}
+
+// TargetEOL returns the name of the line separator applied by Restore: lf or crlf, or cr when a content
+// using it is preserved
+func (c *ContentHelper) TargetEOL() string {
+	switch c.targetEOL() {
+	case crlf:
+		return "crlf"
+	case cr:
+		return "cr"
+	default:
+		return "lf"
+	}
+}
+
+// MixedEOL returns the line and column of the first line ending differing from the first one found,
+// the line is 0 when all line endings are the same
+func (c *ContentHelper) MixedEOL() (int, int) {
+	return c.mixedLine, c.mixedColumn
+}
+
+// HasBom tells whether a BOM was detected
+func (c *ContentHelper) HasBom() bool {
+	return len(c.bom.bom) > 0
+}
+
+// HasFinalNewline tells whether the content ends with a line ending
+func (c *ContentHelper) HasFinalNewline() bool {
+	return c.finalNewline
+}
+
+// Encoding returns the name of the encoding given by the detected BOM: UTF-8, UTF-16LE, UTF-16BE, UTF-32LE,
+// UTF-32BE or an empty string for none
+func (c *ContentHelper) Encoding() string {
+	return c.bom.name
+}
+
+// ConvertsToUTF8 tells whether Restore converts the content to UTF-8, which happens when the BOM giving
+// its encoding is stripped
+func (c *ContentHelper) ConvertsToUTF8() bool {
+	return c.bom.encoding != nil && c.bomPolicy == BOMStrip
 }
 
 // detectBom checks if a content contains a BOM (https://en.wikipedia.org/wiki/Byte_order_mark)
 func (c *ContentHelper) detectBom(content []byte) {
-	bom := []byte{'\xef', '\xbb', '\xbf'}
-	if len(content) >= len(bom) && bytes.Equal(bom, content[:3]) {
-		c.bom = bom
+	c.bom = byteOrderMark{}
+
+	for _, bom := range byteOrderMarks {
+		if bytes.HasPrefix(content, bom.bom) {
+			c.bom = bom
+
+			return
+		}
 	}
 }
 
 // removeBom removes BOM if one was detected and returns the content without it
 func (c *ContentHelper) removeBom(content []byte) []byte {
-	if len(c.bom) > 0 {
-		return content[3:]
+	return content[len(c.bom.bom):]
+}
+
+// addBom adds back a previously detected BOM if any to a content, following the BOM policy.
+// A content without BOM is started with a UTF-8 BOM when one must be added
+func (c *ContentHelper) addBom(content []byte) []byte {
+	switch {
+	case c.bomPolicy == BOMStrip:
+		return content
+	case c.bomPolicy == BOMAdd && len(c.bom.bom) == 0:
+		return append([]byte{'\xef', '\xbb', '\xbf'}, content...)
+	default:
+		return append(append([]byte{}, c.bom.bom...), content...)
+	}
+}
+
+// applyFinalNewline ends a content with a newline or not, following the final newline policy
+func (c *ContentHelper) applyFinalNewline(content []byte) []byte {
+	switch {
+	case c.finalNewlinePolicy == FinalNewlineStrip,
+		c.finalNewlinePolicy == FinalNewlineKeep && !c.finalNewline:
+		return bytes.TrimRight(content, string(lf))
+	case len(content) > 0 && !bytes.HasSuffix(content, []byte(lf)):
+		return append(content, lf...)
+	default:
+		return content
+	}
+}
+
+// decode converts a content in the encoding given by its BOM to UTF-8. Invalid sequences are replaced,
+// the content is returned as is if it can't be decoded
+func (c *ContentHelper) decode(content []byte) []byte {
+	if c.bom.encoding == nil {
+		return content
+	}
+
+	if decoded, err := c.bom.encoding.NewDecoder().Bytes(content); err == nil {
+		return decoded
 	}
 
 	return content
 }
 
-// addBom adds back a previously detected BOM if any to a content
-func (c *ContentHelper) addBom(content []byte) []byte {
-	return append(c.bom, content...)
+// encode converts a UTF-8 content back to the encoding given by the BOM of the original content.
+// A content whose BOM is stripped is left in UTF-8, its encoding could not be detected anymore
+func (c *ContentHelper) encode(content []byte) []byte {
+	if c.bom.encoding == nil || c.bomPolicy == BOMStrip {
+		return content
+	}
+
+	if encoded, err := c.bom.encoding.NewEncoder().Bytes(content); err == nil {
+		return encoded
+	}
+
+	return content
 }
 
-// detectEOL checks a content to find out what is the line separator in the content
+// detectEOL checks a content to find out what is the line separator in the content.
+// The first line separator found is recorded, along with the location of the first one differing from it.
+// A CR is only a line separator in a content without LF, elsewhere it is part of a line like a CR in a doc string
 func (c *ContentHelper) detectEOL(content []byte) {
-	c.eol = noEol
+	c.eol, c.mixedLine, c.mixedColumn = noEol, 0, 0
 
-	var previousChar byte
+	if !bytes.Contains(content, []byte(lf)) {
+		if bytes.Contains(content, []byte(cr)) {
+			c.eol = cr
+		}
 
-	for _, char := range content {
-		switch {
-		case previousChar == '\r' && char == '\n':
-			c.eol = crlf
+		c.finalNewline = bytes.HasSuffix(content, []byte(cr))
 
-			return
-		case previousChar == '\r':
-			c.eol = cr
+		return
+	}
 
-			return
-		case char == '\n':
-			c.eol = lf
+	c.finalNewline = bytes.HasSuffix(content, []byte(lf))
 
-			return
+	line, lineStart := 1, 0
+
+	for i := bytes.IndexByte(content, '\n'); i >= 0; i = bytes.IndexByte(content[lineStart:], '\n') {
+		i += lineStart
+
+		eol, column := lf, i-lineStart+1
+		if i > lineStart && content[i-1] == '\r' {
+			eol, column = crlf, column-1
+		}
+
+		switch {
+		case c.eol == noEol:
+			c.eol = eol
+		case eol != c.eol && c.mixedLine == 0:
+			c.mixedLine, c.mixedColumn = line, column
+		}
+
+		line, lineStart = line+1, i+1
+	}
+}
+
+// targetEOL returns the line separator applied by Restore
+func (c *ContentHelper) targetEOL() eolType {
+	switch c.policy {
+	case EOLLF:
+		return lf
+	case EOLCRLF:
+		return crlf
+	case EOLNative:
+		if runtime.GOOS == "windows" {
+			return crlf
 		}
-		previousChar = char
+
+		return lf
 	}
+
+	if c.eol == noEol {
+		return lf
+	}
+
+	return c.eol
 }
 
-// replaceEOLWithLF replaces the detected EOL with the linux standard line separator
+// replaceEOLWithLF replaces the line separators with the linux standard line separator. CRs that are not
+// line separators are left as is
 func (c *ContentHelper) replaceEOLWithLF(content []byte) []byte {
-	return bytes.ReplaceAll(content, []byte(c.eol), []byte(lf))
+	if c.eol == cr {
+		return bytes.ReplaceAll(content, []byte(cr), []byte(lf))
+	}
+
+	return bytes.ReplaceAll(content, []byte(crlf), []byte(lf))
 }
 
-// replaceEOLWithLF replaces the linux standard line separator with the one detected in content
+// replaceLFWithEOl replaces the linux standard line separator with the one applied by the line ending policy
 func (c *ContentHelper) replaceLFWithEOl(content []byte) []byte {
-	return bytes.ReplaceAll(content, []byte(lf), []byte(c.eol))
+	eol := c.targetEOL()
+	if eol == lf {
+		return content
+	}
+
+	return bytes.ReplaceAll(content, []byte(lf), []byte(eol))
 }
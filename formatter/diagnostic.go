@@ -0,0 +1,16 @@
+package formatter
+
+import "fmt"
+
+// Diagnostic reports a problem found in a feature file that doesn't prevent it from being formatted,
+// like a doc string that looks like JSON but can't be parsed
+type Diagnostic struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+}
@@ -0,0 +1,97 @@
+package formatter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"golang.org/x/net/html/charset"
+)
+
+// unifiedDiff returns a unified diff turning the original content into the formatted one, with headers
+// that can be understood by `git apply`. It returns an empty string when both contents are equal
+func unifiedDiff(file string, original []byte, formatted []byte) string {
+	if bytes.Equal(original, formatted) {
+		return ""
+	}
+
+	name := diffPath(file)
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(original),
+		B:        splitLines(formatted),
+		FromFile: "a/" + name,
+		ToFile:   "b/" + name,
+		Context:  3,
+	})
+
+	return fmt.Sprintf("diff --git a/%s b/%s\n%s", name, name, diff)
+}
+
+// diffPath returns a slash separated path, relative to the root of the git repository containing the working
+// directory, so that patches can be applied from there. Outside a repository, it is relative to the working
+// directory when possible
+func diffPath(file string) string {
+	root, err := gitTopLevel()
+	if err != nil {
+		root, err = os.Getwd()
+	}
+
+	if abs, absErr := filepath.Abs(file); err == nil && absErr == nil {
+		// git resolves symbolic links in the path of the repository
+		rel, err := filepath.Rel(resolveSymlinks(root), resolveSymlinks(abs))
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			file = rel
+		}
+	}
+
+	return filepath.ToSlash(filepath.Clean(file))
+}
+
+// resolveSymlinks returns a path with its symbolic links resolved, or the path as is when they can't be
+func resolveSymlinks(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+
+	return path
+}
+
+// decodeText returns a content decoded from a charset into UTF-8, so that diffs are made of text.
+// A content in UTF-8 or that can't be decoded is returned as is
+func decodeText(content []byte, name string) []byte {
+	e, canonical := charset.Lookup(name)
+
+	for _, mark := range byteOrderMarks {
+		if mark.name == name {
+			e, canonical = mark.encoding, name
+		}
+	}
+
+	if e == nil || canonical == "utf-8" {
+		return content
+	}
+
+	decoded, err := e.NewDecoder().Bytes(content)
+	if err != nil {
+		return content
+	}
+
+	return decoded
+}
+
+// splitLines splits a content into lines keeping their line ending. A last line without line ending
+// gets the marker used by diff tools to tell it apart from the same line followed by a line ending
+func splitLines(content []byte) []string {
+	lines := strings.SplitAfter(string(content), "\n")
+
+	if last := lines[len(lines)-1]; last == "" {
+		lines = lines[:len(lines)-1]
+	} else {
+		lines[len(lines)-1] = last + "\n\\ No newline at end of file\n"
+	}
+
+	return lines
+}
@@ -0,0 +1,31 @@
+Feature: Doc string indentation
+
+Scenario: Keep the indentation of doc strings
+Given a yaml configuration
+        """yaml
+        server:
+          port: 8080
+          routes:
+            - path: /users
+              method: GET
+        """
+And a python script
+"""python
+def greet(name):
+    if name:
+        return "Hello " + name
+
+    return "Hello"
+"""
+      And an ascii art
+          ```
+             /\
+            /  \
+           /____\
+          ```
+  And a log
+    """
+    INFO  starting
+        DEBUG  nested call
+    INFO  done
+    """
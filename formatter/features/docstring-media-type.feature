@@ -0,0 +1,25 @@
+Feature: Doc string media types
+
+  Scenario: Doc strings with a media type
+    Given a json doc string
+      """json
+      {
+        "key1": "value1",
+        "key2": [
+          1,
+          2
+        ]
+      }
+      """
+    And an xml doc string
+      """xml
+      <root>{"key": "value"}</root>
+      """
+    And a doc string declared as text
+      ```text/plain
+      {"key":   "value"}
+      ```
+    And a doc string without media type
+      """
+      1 2 3
+      """
@@ -2,198 +2,660 @@ package formatter
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	mpath "path"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 
-	"github.com/saintfish/chardet"
-	"golang.org/x/net/html/charset"
+	"github.com/bmatcuk/doublestar/v4"
 )
 
 type FileManager struct {
-	indent int
+	options          Options
+	configs          *configResolver
+	excludes         []string
+	respectGitIgnore bool
+	jobCount         int
+	cacheFile        string
+	changedSince     string
+	staged           bool
 }
 
 type ProcessFileError struct {
 	Message string
 	File    string
+	// Err is the error the message comes from, if any
+	Err error
 }
 
 func (p ProcessFileError) Error() string {
 	return fmt.Sprintf(`an error occurred with file "%s" : %s`, p.File, p.Message)
 }
 
+func (p ProcessFileError) Unwrap() error {
+	return p.Err
+}
+
 func NewFileManager(indent int) FileManager {
+	options := DefaultOptions()
+	options.Indent = indent
+
 	return FileManager{
-		indent,
+		options: options,
+	}
+}
+
+// WithOptions returns a copy of the file manager formatting with the given options
+func (f FileManager) WithOptions(options Options) FileManager {
+	f.options = options
+
+	return f
+}
+
+// WithConfigFiles returns a copy of the file manager that looks up a project configuration file
+// (.augurken.yaml, .augurken.yml or .augurken.toml) from the folder of each file up to the filesystem root.
+// Settings found in the closest file replace the file manager options, except the overridden ones
+func (f FileManager) WithConfigFiles(overrides ...string) FileManager {
+	f.configs = newConfigResolver(overrides)
+
+	return f
+}
+
+// WithExcludes returns a copy of the file manager skipping, when walking folders, the files matching
+// the given patterns. Patterns have the gitignore syntax and are relative to the working directory
+func (f FileManager) WithExcludes(patterns ...string) FileManager {
+	f.excludes = append(slices.Clone(f.excludes), patterns...)
+
+	return f
+}
+
+// WithGitIgnore returns a copy of the file manager skipping, when walking folders, the files ignored
+// by the .gitignore files of the repository
+func (f FileManager) WithGitIgnore() FileManager {
+	f.respectGitIgnore = true
+
+	return f
+}
+
+// WithJobs returns a copy of the file manager processing up to n files in parallel. When n is lower than 1,
+// files are processed by as many goroutines as GOMAXPROCS
+func (f FileManager) WithJobs(n int) FileManager {
+	f.jobCount = n
+
+	return f
+}
+
+// WithCache returns a copy of the file manager recording in a cache file the files known to be properly formatted,
+// so they are skipped by the next runs until their content, their settings or the version of augurken change
+func (f FileManager) WithCache(file string) FileManager {
+	f.cacheFile = file
+
+	return f
+}
+
+// WithChangedSince returns a copy of the file manager getting the feature files to process from git:
+// the files changed in the working tree since a ref, untracked files included. Paths given to process files
+// are git pathspecs restricting the files listed.
+// Files listed are skipped as when walking folders
+func (f FileManager) WithChangedSince(ref string) FileManager {
+	f.changedSince = ref
+
+	return f
+}
+
+// WithStaged returns a copy of the file manager getting the feature files to process from the git index.
+// Their staged content is processed rather than the working tree one, and FormatAndReplace writes
+// the formatted content back to the index, and to the working tree file when it has no unstaged changes.
+// Paths given to process files are git pathspecs restricting the files listed.
+// Files listed are skipped as when walking folders
+func (f FileManager) WithStaged() FileManager {
+	f.staged = true
+
+	return f
+}
+
+// Config returns the settings applying to a file
+func (f FileManager) Config(file string) (Config, error) {
+	if f.configs == nil {
+		config := Config{Options: f.options}
+
+		return config, config.validate()
 	}
+
+	return f.configs.resolve(file, f.options)
 }
 
-// FormatAndReplace Format and replace file or path. The function must return either []string or []error
-func (f FileManager) FormatAndReplace(path string) []interface{} {
-	return f.process(path, replaceFileWithContent)
+// FormatAndReplace Format and replace files, paths or glob patterns. It returns a result for each file
+// and for each path or glob pattern that could not be expanded into files
+func (f FileManager) FormatAndReplace(paths ...string) Results {
+	if f.staged {
+		return f.process(paths, replaceStagedFileWithContent)
+	}
+
+	return f.process(paths, replaceFileWithContent)
 }
 
-// Check Test file or path. The function must return either []string or []error
-func (f FileManager) Check(path string) []interface{} {
-	return f.process(path, check)
+// Check Test files, paths or glob patterns. It returns a result for each file and for each path or glob pattern
+// that could not be expanded into files
+func (f FileManager) Check(paths ...string) Results {
+	return f.process(paths, check)
 }
 
+// Format formats a file and returns the formatted content
 func (f FileManager) Format(filename string) ([]byte, error) {
+	result := f.formatFile(filename)
+
+	return result.Formatted, errors.Unwrap(result.Err)
+}
+
+// FormatStream formats a content read from r and writes the result to w. The filename is only used
+// to find the settings applying to the content and to report errors and diagnostics
+func (f FileManager) FormatStream(filename string, r io.Reader, w io.Writer) Result {
+	return f.processStream(filename, r, func(result Result) Result {
+		if _, err := w.Write(result.Formatted); err != nil {
+			return result.fail(StatusIOError, err)
+		}
+
+		return withChangeStatus(result, StatusReformatted)
+	})
+}
+
+// CheckStream tests a content read from r. The filename is only used to find the settings applying
+// to the content and to report errors and diagnostics
+func (f FileManager) CheckStream(filename string, r io.Reader) Result {
+	return f.processStream(filename, r, check)
+}
+
+// formatFile reads and formats a file
+func (f FileManager) formatFile(filename string) Result {
 	content, err := os.ReadFile(filename)
 	if err != nil {
-		return []byte{}, err
+		return Result{Path: filename}.fail(StatusIOError, err)
 	}
 
-	detector := chardet.NewTextDetector()
-	result, err := detector.DetectBest(content)
+	return f.formatContent(filename, content)
+}
 
+// formatContent formats a content along with the diagnostics found in it. The filename is used to find
+// the settings applying to the content and in diagnostics. The status of a formatted content is left
+// for the caller to set
+func (f FileManager) formatContent(filename string, content []byte) Result {
+	config, err := f.Config(filename)
 	if err != nil {
-		return []byte{}, err
+		return Result{Path: filename, Original: content}.fail(StatusIOError, err)
 	}
 
-	if result.Charset != "UTF-8" {
-		r, err := charset.NewReaderLabel(result.Charset, bytes.NewBuffer(content))
-		if err != nil {
-			return []byte{}, err
-		}
+	return formatSource(filename, content, config.Options)
+}
 
-		content, err = io.ReadAll(r)
-		if err != nil {
-			return []byte{}, err
-		}
+// process Handle files, paths or glob patterns depends on processFn value
+func (f FileManager) process(paths []string, processFn func(result Result) Result) Results {
+	var c *cache
+	if f.cacheFile != "" {
+		c = loadCache(f.cacheFile)
 	}
 
-	contentHelper := &ContentHelper{}
-	contentHelper.DetectSettings(content)
-	content = contentHelper.Prepare(content)
+	files, results := f.findFiles(paths)
+	results = append(results, f.processFiles(files, c, processFn)...)
+
+	if err := c.save(); err != nil {
+		result := Result{Path: f.cacheFile, unprocessed: true}
+		results = append(results, result.fail(StatusIOError, err))
+	}
 
-	token, err := parse(content)
+	return results
+}
+
+// processStream Handle a content read from r depends on processFn value
+func (f FileManager) processStream(filename string, r io.Reader, processFn func(result Result) Result) Result {
+	original, err := io.ReadAll(r)
 	if err != nil {
-		return []byte{}, err
+		return Result{Path: filename}.fail(StatusIOError, err)
+	}
+
+	result := f.formatContent(filename, original)
+	if result.Err != nil {
+		return result
 	}
 
-	return contentHelper.Restore(format(token, f.indent)), nil
+	return processFn(result)
 }
 
-// process Handle file or path depends on processFn value. The function must return either []string or []error
-func (f FileManager) process(path string, processFn func(file string, content []byte) error) []interface{} {
-	var result []interface{}
-	fi, err := os.Stat(path)
+// processFiles Handle files depends on processFn value, with as many goroutines as jobs. Results are reported
+// in the order of files whatever the order files are processed in
+func (f FileManager) processFiles(files []string, c *cache, processFn func(result Result) Result) Results {
+	results := make(Results, len(files))
+	fc := make(chan int)
+	wg := sync.WaitGroup{}
 
+	if len(files) == 0 {
+		return results
+	}
+
+	for i := 0; i < min(f.jobs(), len(files)); i++ {
+		wg.Add(1)
+
+		go func() {
+			for index := range fc {
+				results[index] = f.processFile(files[index], c, processFn)
+			}
+
+			wg.Done()
+		}()
+	}
+
+	for index := range files {
+		fc <- index
+	}
+
+	close(fc)
+	wg.Wait()
+
+	return results
+}
+
+// processFile Handle a file depends on processFn value. A file the cache knows to be properly formatted
+// is left unchanged without being parsed
+func (f FileManager) processFile(file string, c *cache, processFn func(result Result) Result) Result {
+	content, err := f.readFile(file)
 	if err != nil {
-		return append(result, err)
+		return Result{Path: file}.fail(StatusIOError, err)
 	}
 
-	switch mode := fi.Mode(); {
-	case mode.IsDir():
-		result = append(result, f.processPath(path, processFn)...)
-	case mode.IsRegular():
-		b, err := f.Format(path)
-		if err != nil {
-			return append(result, err)
-		}
+	config, err := f.Config(file)
+	if err != nil {
+		return Result{Path: file, Original: content}.fail(StatusIOError, err)
+	}
 
-		if err := processFn(path, b); err != nil {
-			return append(result, err)
-		}
+	key := c.key(content, config.Options)
+	if entry, ok := c.lookup(file, key); ok {
+		return entry.apply(Result{Path: file, Status: StatusUnchanged, Original: content, Formatted: content, Cached: true})
+	}
 
-		result = append(result, fmt.Sprint("formatted: ", path))
+	result := formatSource(file, content, config.Options)
+	if result.Err != nil {
+		return result
+	}
+
+	result = processFn(result)
+
+	// Files with diagnostics are formatted again to report them on each run
+	if result.Status == StatusUnchanged && len(result.Diagnostics) == 0 {
+		c.store(file, key, result)
 	}
 
 	return result
 }
 
-// processPath Handle path depends on processFn value. The function must return either []string or []error
-func (f FileManager) processPath(path string, processFn func(file string, content []byte) error) []interface{} {
-	var result []interface{}
-	fc := make(chan string)
-	wg := sync.WaitGroup{}
+// readFile returns the content of a file, or its staged content when files come from the git index
+func (f FileManager) readFile(file string) ([]byte, error) {
+	if f.staged {
+		return gitStagedContent(file)
+	}
 
-	files, err := findFeatureFiles(path)
+	return os.ReadFile(file)
+}
+
+// jobs returns the number of files processed in parallel
+func (f FileManager) jobs() int {
+	if f.jobCount < 1 {
+		return runtime.GOMAXPROCS(0)
+	}
+
+	return f.jobCount
+}
+
+// replaceFileWithContent writes the formatted content over the file unless it is already properly formatted
+func replaceFileWithContent(result Result) Result {
+	result = withChangeStatus(result, StatusReformatted)
+	if result.Status == StatusUnchanged {
+		return result
+	}
+
+	if err := writeFileAtomically(result.Path, result.Formatted); err != nil {
+		return result.fail(StatusIOError, err)
+	}
+
+	return result
+}
+
+// replaceStagedFileWithContent writes the formatted content to the git index unless the staged content is already
+// properly formatted. The working tree file is written too when it has no unstaged changes, partially staged files
+// keep their unstaged changes
+func replaceStagedFileWithContent(result Result) Result {
+	result = withChangeStatus(result, StatusReformatted)
+	if result.Status == StatusUnchanged {
+		return result
+	}
+
+	if err := gitStage(result.Path, result.Formatted); err != nil {
+		return result.fail(StatusIOError, err)
+	}
+
+	if current, err := os.ReadFile(result.Path); err != nil || !bytes.Equal(current, result.Original) {
+		return result
+	}
+
+	if err := writeFileAtomically(result.Path, result.Formatted); err != nil {
+		return result.fail(StatusIOError, err)
+	}
+
+	return result
+}
+
+// writeFileAtomically replaces a file with a content written to a temporary file of the same folder first,
+// so the file is never left truncated. A read-only file is not replaced, the mode, owner and group of the file
+// are kept when possible and the target of a symbolic link is written rather than the link itself
+func writeFileAtomically(file string, content []byte) error {
+	file, err := filepath.EvalSymlinks(file)
 	if err != nil {
-		return append(result, err)
+		return err
 	}
 
-	if len(files) == 0 {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	// Renaming only requires to write the folder, the file must be writable to be replaced
+	if err := checkWritable(file, info); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		// The temporary file is already renamed when everything went well
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	// Changing the owner may clear the setuid and setgid bits, the mode is set afterwards
+	copyOwner(tmp.Name(), info)
+
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file)
+}
+
+// checkWritable fails when a file can't be written. Privileged users can open any file, so a file without
+// write permission for its owner is considered read-only too
+func checkWritable(file string, info os.FileInfo) error {
+	if info.Mode().Perm()&0o200 == 0 {
+		return fmt.Errorf("%s is read-only", file)
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// check fails when the original content is not properly formatted
+func check(result Result) Result {
+	result = withChangeStatus(result, StatusUnformatted)
+	if result.Status != StatusUnformatted {
 		return result
 	}
 
-	for i := 0; i < 10; i++ {
-		wg.Add(1)
+	message := "file is not properly formatted"
 
-		go func() {
-			for file := range fc {
-				b, err := f.Format(file)
+	contentHelper := &ContentHelper{}
+	contentHelper.DetectSettings(result.Formatted)
+
+	if eol := contentHelper.EOL(); result.EOL != "" && result.EOL != eol {
+		message = fmt.Sprintf("%s, line endings are %s instead of %s", message, result.EOL, eol)
+	}
+
+	switch {
+	case result.BOM && !contentHelper.HasBom():
+		message += ", the BOM must be removed"
+	case !result.BOM && contentHelper.HasBom():
+		message += ", a BOM is missing"
+	}
 
-				if err != nil {
-					result = append(result, ProcessFileError{Message: err.Error(), File: file})
+	original := &ContentHelper{}
+	original.DetectSettings(result.Original)
 
-					continue
-				}
+	switch {
+	case original.HasFinalNewline() && !contentHelper.HasFinalNewline():
+		message += ", the final newline must be removed"
+	case !original.HasFinalNewline() && contentHelper.HasFinalNewline():
+		message += ", the final newline is missing"
+	}
 
-				if err := processFn(file, b); err != nil {
-					result = append(result, err)
+	result.Err = ProcessFileError{Message: message, File: result.Path}
 
-					continue
-				}
+	return result
+}
+
+// withChangeStatus returns a copy of the result with the given status when the formatted content differs
+// from the original one, and unchanged otherwise
+func withChangeStatus(result Result, status Status) Result {
+	result.Status = StatusUnchanged
+	if !bytes.Equal(result.Original, result.Formatted) {
+		result.Status = status
+	}
+
+	return result
+}
+
+// findFiles expands files, paths and glob patterns into a sorted list of files where each file appears only once.
+// Paths are walked to find feature files, glob patterns support `**` to match any number of folders.
+// The function returns the files found and a failed result for each path that could not be expanded
+func (f FileManager) findFiles(paths []string) ([]string, Results) {
+	var (
+		files  []string
+		errs   Results
+		founds = map[string]bool{}
+	)
+
+	// All files listed by git are processed when no path restricts them
+	if (f.staged || f.changedSince != "") && len(paths) == 0 {
+		paths = []string{"."}
+	}
 
-				result = append(result, fmt.Sprint("formatted: ", file))
+	for _, path := range paths {
+		matches, err := f.expandPath(path)
+		if err != nil {
+			errs = append(errs, Result{Path: path, Status: StatusIOError, Err: err, unprocessed: true})
+
+			continue
+		}
+
+		for _, match := range matches {
+			if file := filepath.Clean(match); !founds[file] {
+				founds[file] = true
+				files = append(files, file)
 			}
+		}
+	}
 
-			wg.Done()
-		}()
+	sort.Strings(files)
+
+	return files, errs
+}
+
+// expandPath returns the file itself, the feature files found in a path or the feature files matching
+// a glob pattern. When files come from git, it returns the feature files listed by git for the path
+func (f FileManager) expandPath(path string) ([]string, error) {
+	if f.staged || f.changedSince != "" {
+		return f.findGitFeatureFiles(path)
 	}
 
-	for _, file := range files {
-		fc <- file
+	fi, err := os.Stat(path)
+	if err != nil && isGlobPattern(path) {
+		return findGlobFeatureFiles(path)
 	}
 
-	close(fc)
-	wg.Wait()
+	if err != nil {
+		return []string{}, err
+	}
 
-	return result
+	if fi.IsDir() {
+		return f.findFeatureFiles(path)
+	}
+
+	return []string{path}, nil
 }
 
-func replaceFileWithContent(file string, content []byte) error {
-	f, err := os.Create(file)
+// findGitFeatureFiles returns the feature files listed by git for a path, without the files skipped when walking
+// folders: files matched by ignore files or excludes, and files excluded by their configuration.
+// A file given as the path itself is kept, as when it is given without git
+func (f FileManager) findGitFeatureFiles(path string) ([]string, error) {
+	var (
+		files []string
+		err   error
+	)
+
+	if f.staged {
+		files, err = gitStagedFiles(path)
+	} else {
+		files, err = gitChangedFiles(f.changedSince, path)
+	}
+
 	if err != nil {
-		return ProcessFileError{Message: err.Error(), File: file}
+		return []string{}, err
 	}
 
-	_, err = f.Write(content)
+	// Files are skipped as if the root of the repository was walked, they may be outside the working directory
+	top, err := gitTopLevel()
 	if err != nil {
-		return ProcessFileError{Message: err.Error(), File: file}
+		return []string{}, err
+	}
+
+	ignorer, err := newIgnorer(top, f.excludes, f.respectGitIgnore)
+	if err != nil {
+		return []string{}, err
 	}
 
-	return nil
+	kept := []string{}
+
+	for _, file := range files {
+		if file == filepath.Clean(path) {
+			kept = append(kept, file)
+
+			continue
+		}
+
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return []string{}, err
+		}
+
+		// git resolves symbolic links in the path of the repository
+		if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+			abs = filepath.Join(dir, filepath.Base(abs))
+		}
+
+		ignored, err := ignorer.isIgnoredBelow(top, abs, false)
+		if err != nil {
+			return []string{}, err
+		}
+
+		if config, err := f.Config(file); !ignored && (err != nil || !config.isExcluded(file)) {
+			kept = append(kept, file)
+		}
+	}
+
+	return kept, nil
+}
+
+func isGlobPattern(path string) bool {
+	return strings.ContainsAny(path, "*?[{")
 }
 
-func check(file string, content []byte) error {
-	currentContent, err := os.ReadFile(file)
+func findGlobFeatureFiles(pattern string) ([]string, error) {
+	var files []string
+
+	matches, err := doublestar.FilepathGlob(pattern, doublestar.WithFilesOnly())
 	if err != nil {
-		return ProcessFileError{Message: err.Error(), File: file}
+		return []string{}, fmt.Errorf("invalid pattern %q: %w", pattern, err)
 	}
 
-	if !bytes.Equal(currentContent, content) {
-		return ProcessFileError{Message: "file is not properly formatted", File: file}
+	for _, match := range matches {
+		if mpath.Ext(match) == ".feature" {
+			files = append(files, match)
+		}
 	}
 
-	return nil
+	if len(files) == 0 {
+		return []string{}, fmt.Errorf("no feature files match %q", pattern)
+	}
+
+	return files, nil
 }
 
-func findFeatureFiles(rootPath string) ([]string, error) {
+// findFeatureFiles walks a folder to find feature files. Files and folders matched by .augurkenignore files,
+// excludes or .gitignore files if enabled are skipped, as well as files excluded by their configuration.
+// A file whose configuration can't be read is kept, so the error is reported when formatting it
+func (f FileManager) findFeatureFiles(rootPath string) ([]string, error) {
 	var files []string
 
+	absRootPath, err := filepath.Abs(rootPath)
+	if err != nil {
+		return []string{}, err
+	}
+
+	ignorer, err := newIgnorer(absRootPath, f.excludes, f.respectGitIgnore)
+	if err != nil {
+		return []string{}, err
+	}
+
 	if err := filepath.Walk(rootPath, func(p string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if !info.IsDir() && mpath.Ext(p) == ".feature" {
+		rel, err := filepath.Rel(rootPath, p)
+		if err != nil {
+			return err
+		}
+
+		abs := filepath.Join(absRootPath, rel)
+
+		if info.IsDir() {
+			if rel != "." && ignorer.isIgnored(abs, true) {
+				return filepath.SkipDir
+			}
+
+			return ignorer.read(abs)
+		}
+
+		if mpath.Ext(p) != ".feature" || ignorer.isIgnored(abs, false) {
+			return nil
+		}
+
+		if config, err := f.Config(p); err != nil || !config.isExcluded(p) {
 			files = append(files, p)
 		}
 
@@ -1,11 +1,18 @@
 package formatter
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/unicode"
 )
 
 func TestFileManagerFormat(t *testing.T) { //nolint:tparallel
@@ -55,6 +62,36 @@ func TestFileManagerFormat(t *testing.T) { //nolint:tparallel
 				assert.EqualValues(t, string(b), string(buf))
 			},
 		},
+		{
+			"features/docstring-media-type.feature",
+			func(buf []byte, err error) {
+				assert.NoError(t, err)
+
+				b, e := os.ReadFile("features/docstring-media-type.feature")
+				assert.NoError(t, e)
+				assert.EqualValues(t, string(b), string(buf))
+			},
+		},
+		{
+			"features/docstring-indentation.input.feature",
+			func(buf []byte, err error) {
+				assert.NoError(t, err)
+
+				b, e := os.ReadFile("features/docstring-indentation.expected.feature")
+				assert.NoError(t, e)
+				assert.EqualValues(t, string(b), string(buf))
+			},
+		},
+		{
+			"features/docstring-indentation.expected.feature",
+			func(buf []byte, err error) {
+				assert.NoError(t, err)
+
+				b, e := os.ReadFile("features/docstring-indentation.expected.feature")
+				assert.NoError(t, e)
+				assert.EqualValues(t, string(b), string(buf))
+			},
+		},
 		{
 			"features/escape-new-line.feature",
 			func(buf []byte, err error) {
@@ -95,6 +132,46 @@ func TestFileManagerFormat(t *testing.T) { //nolint:tparallel
 				assert.EqualValues(t, string(b), string(buf))
 			},
 		},
+		{
+			"features/utf16le-with-bom.feature",
+			func(buf []byte, err error) {
+				assert.NoError(t, err)
+
+				b, e := os.ReadFile("features/utf16le-with-bom.feature")
+				assert.NoError(t, e)
+				assert.EqualValues(t, string(b), string(buf))
+			},
+		},
+		{
+			"features/utf16be-with-bom.feature",
+			func(buf []byte, err error) {
+				assert.NoError(t, err)
+
+				b, e := os.ReadFile("features/utf16be-with-bom.feature")
+				assert.NoError(t, e)
+				assert.EqualValues(t, string(b), string(buf))
+			},
+		},
+		{
+			"features/utf32le-with-bom.feature",
+			func(buf []byte, err error) {
+				assert.NoError(t, err)
+
+				b, e := os.ReadFile("features/utf32le-with-bom.feature")
+				assert.NoError(t, e)
+				assert.EqualValues(t, string(b), string(buf))
+			},
+		},
+		{
+			"features/utf32be-with-bom.feature",
+			func(buf []byte, err error) {
+				assert.NoError(t, err)
+
+				b, e := os.ReadFile("features/utf32be-with-bom.feature")
+				assert.NoError(t, e)
+				assert.EqualValues(t, string(b), string(buf))
+			},
+		},
 		{
 			"features/file1-with-cr.feature",
 			func(buf []byte, err error) {
@@ -164,7 +241,7 @@ func TestFileManagerFormatAndReplace(t *testing.T) {
 		testName string
 		path     string
 		setup    func()
-		test     func([]interface{})
+		test     func(Results)
 	}
 
 	scenarios := []scenario{
@@ -187,7 +264,7 @@ hello world
 				assert.NoError(t, os.MkdirAll("tmp/", 0o777))
 				assert.NoError(t, os.WriteFile("tmp/file1.feature", content, 0o600))
 			},
-			func(output []interface{}) {
+			func(output Results) {
 				assertNoErrors(t, output)
 
 				content := `Feature: test
@@ -227,7 +304,7 @@ Examples:
 				assert.NoError(t, os.MkdirAll("tmp/", 0o777))
 				assert.NoError(t, os.WriteFile("tmp/file1.feature", content, 0o600))
 			},
-			func(output []interface{}) {
+			func(output Results) {
 				assertNoErrors(t, output)
 
 				content := `Feature: test feature
@@ -248,6 +325,73 @@ Examples:
 				assert.EqualValues(t, content, string(b))
 			},
 		},
+		{
+			"format doc string depending on media type",
+			"tmp/file1.feature",
+			func() {
+				content := []byte(`Feature: test feature
+
+Scenario: Doc string media type
+Given json data:
+  """application/json
+  {"key1": "value2",    "key2": [1, 2]}
+  """
+And xml data:
+  """xml
+  <root>{"key": "value"}</root>
+  """
+And numbers:
+  """
+  1 2 3
+  """
+And an undeclared json:
+  """
+  [1,    2]
+  """
+`)
+
+				assert.NoError(t, os.RemoveAll("tmp/"))
+				assert.NoError(t, os.MkdirAll("tmp/", 0o777))
+				assert.NoError(t, os.WriteFile("tmp/file1.feature", content, 0o600))
+			},
+			func(output Results) {
+				assertNoErrors(t, output)
+
+				content := `Feature: test feature
+
+  Scenario: Doc string media type
+    Given json data:
+      """application/json
+      {
+        "key1": "value2",
+        "key2": [
+          1,
+          2
+        ]
+      }
+      """
+    And xml data:
+      """xml
+      <root>{"key": "value"}</root>
+      """
+    And numbers:
+      """
+      1 2 3
+      """
+    And an undeclared json:
+      """
+      [
+        1,
+        2
+      ]
+      """
+`
+
+				b, e := os.ReadFile("tmp/file1.feature")
+				assert.NoError(t, e)
+				assert.EqualValues(t, content, string(b))
+			},
+		},
 		{
 			"format bullet points",
 			"tmp/file1.feature",
@@ -266,7 +410,7 @@ Then all is good
 				assert.NoError(t, os.MkdirAll("tmp/", 0o777))
 				assert.NoError(t, os.WriteFile("tmp/file1.feature", content, 0o600))
 			},
-			func(output []interface{}) {
+			func(output Results) {
 				assertNoErrors(t, output)
 
 				content := `Feature: bullet points
@@ -315,7 +459,7 @@ hello world
 					assert.NoError(t, os.WriteFile(f, []byte(fmt.Sprintf(string(content), i)), 0o600))
 				}
 			},
-			func(output []interface{}) {
+			func(output Results) {
 				assertNoErrors(t, output)
 
 				content := `Feature: test
@@ -368,7 +512,7 @@ hello world
 				assert.NoError(t, os.WriteFile("tmp/test1/file4.feature", content, 0o600))
 				assert.NoError(t, os.WriteFile("tmp/test1/file5.feature", append([]byte("something"), content...), 0o600))
 			},
-			func(output []interface{}) {
+			func(output Results) {
 				assert.Len(t, output, 5)
 
 				expectedErrs := []string{
@@ -381,8 +525,7 @@ hello world
 				i := 0
 				for _, expectedErr := range expectedErrs {
 					for _, o := range output {
-						e, ok := o.(error)
-						if ok && expectedErr == e.Error() {
+						if o.Err != nil && expectedErr == o.Err.Error() {
 							i++
 						}
 					}
@@ -401,7 +544,7 @@ hello world
 				assert.NoError(t, os.WriteFile("tmp/file1.txt", []byte("file1"), 0o600))
 				assert.NoError(t, os.WriteFile("tmp/file2.txt", []byte("file2"), 0o600))
 			},
-			func(output []interface{}) {
+			func(output Results) {
 				assertNoErrors(t, output)
 			},
 		},
@@ -409,9 +552,9 @@ hello world
 			"format an unexisting folder",
 			"whatever/whatever",
 			func() {},
-			func(output []interface{}) {
+			func(output Results) {
 				assert.Len(t, output, 1)
-				e, _ := output[0].(error)
+				e := output[0].Err
 				assert.Error(t, e)
 				assert.EqualError(t, e, "stat whatever/whatever: no such file or directory")
 			},
@@ -420,9 +563,9 @@ hello world
 			"format an invalid file",
 			"features/invalid.feature",
 			func() {},
-			func(output []interface{}) {
+			func(output Results) {
 				assert.Len(t, output, 1)
-				e, _ := output[0].(error)
+				e := output[0].Err
 				assert.Error(t, e)
 			},
 		},
@@ -439,12 +582,81 @@ hello world
 	}
 }
 
+func TestFileManagerFormatAndReplaceWritesAtomically(t *testing.T) {
+	formatted := []byte(`Feature: test
+
+  Scenario: scenario
+    Given whatever
+`)
+
+	assert.NoError(t, os.RemoveAll("tmp"))
+	assert.NoError(t, os.MkdirAll("tmp", 0o777))
+	assert.NoError(t, os.WriteFile("tmp/file1.feature", []byte("Feature: test\n\nScenario: scenario\nGiven whatever\n"), 0o640))
+	assert.NoError(t, os.WriteFile("tmp/file2.feature", formatted, 0o600))
+	assert.NoError(t, os.Symlink("file1.feature", "tmp/link.feature"))
+
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	assert.NoError(t, os.Chtimes("tmp/file2.feature", past, past))
+
+	output := NewFileManager(2).FormatAndReplace("tmp/link.feature", "tmp/file2.feature")
+
+	assertNoErrors(t, output)
+	assert.Equal(t, []Status{StatusUnchanged, StatusReformatted}, []Status{output[0].Status, output[1].Status})
+
+	b, err := os.ReadFile("tmp/file1.feature")
+	assert.NoError(t, err)
+	assert.EqualValues(t, formatted, b)
+
+	info, err := os.Stat("tmp/file1.feature")
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+
+	info, err = os.Lstat("tmp/link.feature")
+	assert.NoError(t, err)
+	assert.Equal(t, os.ModeSymlink, info.Mode().Type())
+
+	info, err = os.Stat("tmp/file2.feature")
+	assert.NoError(t, err)
+	assert.True(t, info.ModTime().Equal(past))
+
+	entries, err := os.ReadDir("tmp")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	// Cleanup
+	_ = os.RemoveAll("tmp/")
+}
+
+func TestFileManagerFormatAndReplaceReadOnlyFile(t *testing.T) {
+	unformatted := []byte("Feature: test\n\nScenario: scenario\nGiven whatever\n")
+
+	assert.NoError(t, os.RemoveAll("tmp"))
+	assert.NoError(t, os.MkdirAll("tmp", 0o777))
+	assert.NoError(t, os.WriteFile("tmp/file1.feature", unformatted, 0o400))
+
+	output := NewFileManager(2).FormatAndReplace("tmp/file1.feature")
+
+	assert.Equal(t, Summary{Files: 1, IOErrors: 1}, output.Summary())
+	assert.EqualError(t, output[0].Err, `an error occurred with file "tmp/file1.feature" : tmp/file1.feature is read-only`)
+
+	b, err := os.ReadFile("tmp/file1.feature")
+	assert.NoError(t, err)
+	assert.EqualValues(t, unformatted, b)
+
+	entries, err := os.ReadDir("tmp")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// Cleanup
+	_ = os.RemoveAll("tmp/")
+}
+
 func TestFileManagerCheck(t *testing.T) {
 	type scenario struct {
 		testName string
 		path     string
 		setup    func()
-		test     func([]interface{})
+		test     func(Results)
 	}
 
 	scenarios := []scenario{
@@ -467,13 +679,101 @@ hello world
 				assert.NoError(t, os.MkdirAll("tmp", 0o777))
 				assert.NoError(t, os.WriteFile("tmp/file1.feature", content, 0o600))
 			},
-			func(output []interface{}) {
+			func(output Results) {
 				assert.Len(t, output, 1)
-				e, _ := output[0].(error)
+				e := output[0].Err
 				assert.Error(t, e)
 				assert.EqualError(t, e, `an error occurred with file "tmp/file1.feature" : file is not properly formatted`)
 			},
 		},
+		{
+			"Check a file without final newline",
+			"tmp/file1.feature",
+			func() {
+				content := []byte(`Feature: test
+
+  Scenario: scenario
+    Given whatever`)
+
+				assert.NoError(t, os.RemoveAll("tmp"))
+				assert.NoError(t, os.MkdirAll("tmp", 0o777))
+				assert.NoError(t, os.WriteFile("tmp/file1.feature", content, 0o600))
+			},
+			func(output Results) {
+				assert.Len(t, output, 1)
+				assert.Equal(t, StatusUnformatted, output[0].Status)
+				name := diffPrefix(t) + "tmp/file1.feature"
+				assert.EqualValues(t, "diff --git a/"+name+" b/"+name+"\n--- a/"+name+"\n+++ b/"+name+`
+@@ -1,4 +1,4 @@
+ Feature: test
+ 
+   Scenario: scenario
+-    Given whatever
+\ No newline at end of file
++    Given whatever
+`, output[0].Diff())
+			},
+		},
+		{
+			"Check a file in UTF-16 wrongly formatted",
+			"tmp/file1.feature",
+			func() {
+				content, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().Bytes(
+					[]byte("Feature: test\n\nScenario: scenario\n"),
+				)
+				assert.NoError(t, err)
+
+				assert.NoError(t, os.RemoveAll("tmp"))
+				assert.NoError(t, os.MkdirAll("tmp", 0o777))
+				assert.NoError(t, os.WriteFile("tmp/file1.feature", content, 0o600))
+			},
+			func(output Results) {
+				assert.Len(t, output, 1)
+				assert.Equal(t, StatusUnformatted, output[0].Status)
+				// The diff is made of the decoded contents
+				assert.Contains(t, output[0].Diff(), "@@ -1,3 +1,3 @@\n \ufeffFeature: test\n \n-Scenario: scenario\n+  Scenario: scenario\n")
+			},
+		},
+		{
+			"Check a file with invalid JSON in a doc string",
+			"tmp/file1.feature",
+			func() {
+				content := []byte(`Feature: test
+
+  Scenario: scenario
+    Given whatever
+      """json
+      {
+        "key1": "value1",
+        "key2": }
+      }
+      """
+    Then whatever
+      """
+      [1, 2,, 3]
+      """
+`)
+
+				assert.NoError(t, os.RemoveAll("tmp"))
+				assert.NoError(t, os.MkdirAll("tmp", 0o777))
+				assert.NoError(t, os.WriteFile("tmp/file1.feature", content, 0o600))
+			},
+			func(output Results) {
+				assert.Len(t, output, 1)
+
+				var diagnostics []string
+				for _, d := range output[0].Diagnostics {
+					diagnostics = append(diagnostics, d.Error())
+				}
+
+				// The second doc string is only guessed to be JSON, it is not reported
+				assert.EqualValues(t, []string{
+					"tmp/file1.feature:8:17: invalid character '}' looking for beginning of value",
+				}, diagnostics)
+				assert.Equal(t, StatusUnchanged, output[0].Status)
+				assert.Equal(t, 1, output.Summary().Diagnostics)
+			},
+		},
 		{
 			"Check a file correctly formatted",
 			"tmp/file1.feature",
@@ -492,7 +792,7 @@ hello world
 				assert.NoError(t, os.MkdirAll("tmp", 0o777))
 				assert.NoError(t, os.WriteFile("tmp/file1.feature", content, 0o600))
 			},
-			func(output []interface{}) {
+			func(output Results) {
 				assertNoErrors(t, output)
 			},
 		},
@@ -527,7 +827,7 @@ hello world
 					assert.NoError(t, os.WriteFile(f, []byte(fmt.Sprintf(string(content), i)), 0o600))
 				}
 			},
-			func(output []interface{}) {
+			func(output Results) {
 				assert.Len(t, output, 6)
 
 				expectedErrs := []string{
@@ -542,8 +842,7 @@ hello world
 				i := 0
 				for _, expectedErr := range expectedErrs {
 					for _, o := range output {
-						e, ok := o.(error)
-						if ok && expectedErr == e.Error() {
+						if o.Err != nil && expectedErr == o.Err.Error() {
 							i++
 						}
 					}
@@ -583,7 +882,7 @@ hello world
 					assert.NoError(t, os.WriteFile(f, []byte(fmt.Sprintf(string(content), i)), 0o600))
 				}
 			},
-			func(output []interface{}) {
+			func(output Results) {
 				assertNoErrors(t, output)
 			},
 		},
@@ -611,7 +910,7 @@ hello world
 				assert.NoError(t, os.WriteFile("tmp/test1/file4.feature", content, 0o600))
 				assert.NoError(t, os.WriteFile("tmp/test1/file5.feature", append([]byte("something"), content...), 0o600))
 			},
-			func(output []interface{}) {
+			func(output Results) {
 				assert.Len(t, output, 5)
 
 				expectedErrs := []string{
@@ -624,8 +923,7 @@ hello world
 				i := 0
 				for _, expectedErr := range expectedErrs {
 					for _, o := range output {
-						e, ok := o.(error)
-						if ok && expectedErr == e.Error() {
+						if o.Err != nil && expectedErr == o.Err.Error() {
 							i++
 						}
 					}
@@ -644,7 +942,7 @@ hello world
 				assert.NoError(t, os.WriteFile("tmp/file1.txt", []byte("file1"), 0o600))
 				assert.NoError(t, os.WriteFile("tmp/file2.txt", []byte("file2"), 0o600))
 			},
-			func(output []interface{}) {
+			func(output Results) {
 				assertNoErrors(t, output)
 			},
 		},
@@ -652,9 +950,9 @@ hello world
 			"Check an unexisting folder",
 			"whatever/whatever",
 			func() {},
-			func(output []interface{}) {
+			func(output Results) {
 				assert.Len(t, output, 1)
-				e, _ := output[0].(error)
+				e := output[0].Err
 				assert.Error(t, e)
 				assert.EqualError(t, e, "stat whatever/whatever: no such file or directory")
 			},
@@ -663,9 +961,9 @@ hello world
 			"Check an invalid file",
 			"features/invalid.feature",
 			func() {},
-			func(output []interface{}) {
+			func(output Results) {
 				assert.Len(t, output, 1)
-				e, _ := output[0].(error)
+				e := output[0].Err
 				assert.Error(t, e)
 			},
 		},
@@ -684,10 +982,600 @@ hello world
 	}
 }
 
-func assertNoErrors(t *testing.T, any []interface{}) {
-	for _, a := range any {
-		if _, ok := a.(error); ok {
-			assert.Fail(t, "An error is not expected.", a)
+func TestFileManagerCheckSeveralPaths(t *testing.T) {
+	content := []byte(`Feature: test
+
+  Scenario: scenario
+    Given whatever
+`)
+
+	assert.NoError(t, os.RemoveAll("tmp"))
+	assert.NoError(t, os.MkdirAll("tmp/api", 0o777))
+	assert.NoError(t, os.MkdirAll("tmp/ui/checkout", 0o777))
+
+	for _, f := range []string{
+		"tmp/api/file1.feature",
+		"tmp/api/file2.feature",
+		"tmp/ui/checkout/checkout1.feature",
+		"tmp/ui/checkout/checkout2.feature",
+		"tmp/ui/checkout/payment.feature",
+		"tmp/ui/checkout/checkout.txt",
+	} {
+		assert.NoError(t, os.WriteFile(f, content, 0o600))
+	}
+
+	f := NewFileManager(2).WithJobs(3)
+	output := f.Check("tmp/**/checkout*.feature", "tmp/api", "tmp/api/file1.feature", "tmp/ui/checkout/checkout2.feature")
+
+	assertNoErrors(t, output)
+	assert.Equal(t, []string{
+		"tmp/api/file1.feature",
+		"tmp/api/file2.feature",
+		"tmp/ui/checkout/checkout1.feature",
+		"tmp/ui/checkout/checkout2.feature",
+	}, paths(output))
+
+	output = f.Check("tmp/api", "tmp/**/unknown*.feature", "tmp/unknown")
+
+	assert.Len(t, output, 4)
+	assert.EqualError(t, output[0].Err, `no feature files match "tmp/**/unknown*.feature"`)
+	assert.EqualError(t, output[1].Err, "stat tmp/unknown: no such file or directory")
+	// Paths that could not be expanded are not counted as files
+	assert.Equal(t, Summary{Files: 2, Unchanged: 2, OtherErrors: 2}, output.Summary())
+	assert.Equal(t, 2, output.Summary().Failures())
+
+	// Cleanup
+	_ = os.RemoveAll("tmp/")
+}
+
+func TestFileManagerConfigFiles(t *testing.T) {
+	content := []byte(`Feature: test
+
+Scenario: scenario
+Given whatever
+"""
+{"key": "value"}
+"""
+Examples:
+| data               |
+| {"key":   "value"} |
+`)
+
+	assert.NoError(t, os.RemoveAll("tmp"))
+	assert.NoError(t, os.MkdirAll("tmp/vendor", 0o777))
+	assert.NoError(t, os.MkdirAll("tmp/sub", 0o777))
+	assert.NoError(t, os.WriteFile("tmp/.augurken.yaml", []byte(`indent: 4
+exclude:
+  - vendor/**
+docstring:
+  detect-json: false
+table:
+  compact-json: false
+`), 0o600))
+	assert.NoError(t, os.WriteFile("tmp/sub/.augurken.toml", []byte("indent = 3\n"), 0o600))
+
+	for _, f := range []string{"tmp/file1.feature", "tmp/vendor/file2.feature", "tmp/sub/file3.feature"} {
+		assert.NoError(t, os.WriteFile(f, content, 0o600))
+	}
+
+	f := NewFileManager(2).WithConfigFiles()
+	output := f.FormatAndReplace("tmp")
+
+	assertNoErrors(t, output)
+	assert.Equal(t, []string{"tmp/file1.feature", "tmp/sub/file3.feature"}, paths(output))
+	assert.Equal(t, Summary{Files: 2, Reformatted: 2}, output.Summary())
+
+	b, err := os.ReadFile("tmp/file1.feature")
+	assert.NoError(t, err)
+	assert.EqualValues(t, `Feature: test
+
+    Scenario: scenario
+        Given whatever
+            """
+            {"key": "value"}
+            """
+        Examples:
+            | data               |
+            | {"key":   "value"} |
+`, string(b))
+
+	b, err = os.ReadFile("tmp/sub/file3.feature")
+	assert.NoError(t, err)
+	assert.EqualValues(t, `Feature: test
+
+   Scenario: scenario
+      Given whatever
+         """
+         {
+            "key": "value"
+         }
+         """
+      Examples:
+         | data            |
+         | {"key":"value"} |
+`, string(b))
+
+	b, err = os.ReadFile("tmp/vendor/file2.feature")
+	assert.NoError(t, err)
+	assert.EqualValues(t, content, b)
+
+	// Explicit files are formatted even if excluded, and overridden settings are kept
+	f = NewFileManager(2).WithConfigFiles(SettingIndent)
+	output = f.FormatAndReplace("tmp/vendor/file2.feature")
+
+	assertNoErrors(t, output)
+
+	b, err = os.ReadFile("tmp/vendor/file2.feature")
+	assert.NoError(t, err)
+	assert.EqualValues(t, `Feature: test
+
+  Scenario: scenario
+    Given whatever
+      """
+      {"key": "value"}
+      """
+    Examples:
+      | data               |
+      | {"key":   "value"} |
+`, string(b))
+
+	// Media types containing dots are kept as keys of the doc string formatters
+	assert.NoError(t, os.WriteFile("tmp/sub/.augurken.yaml", []byte(`docstring:
+  formatters:
+    application/vnd.api+json: none
+    application/vnd.api.v2+json: json
+`), 0o600))
+
+	config, err := NewFileManager(2).WithConfigFiles().Config("tmp/sub/file3.feature")
+
+	assert.NoError(t, err)
+	assert.Equal(t, absPath(t, "tmp/sub/.augurken.yaml"), config.File)
+	assert.Equal(
+		t,
+		map[string]string{"application/vnd.api+json": "none", "application/vnd.api.v2+json": "json"},
+		config.DocStringFormatters,
+	)
+	assert.NoError(t, os.Remove("tmp/sub/.augurken.yaml"))
+
+	assert.NoError(t, os.WriteFile("tmp/sub/.augurken.toml", []byte("[docstring.formatters]\njson = \"xml\"\n"), 0o600))
+
+	output = NewFileManager(2).WithConfigFiles().Check("tmp/sub/file3.feature")
+
+	assert.Len(t, output, 1)
+	assert.Equal(t, StatusIOError, output[0].Status)
+	assert.EqualError(t, output[0].Err, fmt.Sprintf(
+		`an error occurred with file "tmp/sub/file3.feature" : invalid configuration file %s: `+
+			`unknown doc string formatter "xml" for media type "json", expected "json" or "none"`,
+		absPath(t, "tmp/sub/.augurken.toml"),
+	))
+
+	// Cleanup
+	_ = os.RemoveAll("tmp/")
+}
+
+func TestFileManagerIgnoreFiles(t *testing.T) {
+	content := []byte(`Feature: test
+
+  Scenario: scenario
+    Given whatever
+`)
+
+	assert.NoError(t, os.RemoveAll("tmp"))
+
+	for _, d := range []string{"tmp/features/vendor", "tmp/features/api/generated", "tmp/features/ui", "tmp/.git"} {
+		assert.NoError(t, os.MkdirAll(d, 0o777))
+	}
+
+	assert.NoError(t, os.WriteFile("tmp/.gitignore", []byte("ui/\n"), 0o600))
+	assert.NoError(t, os.WriteFile("tmp/features/.augurkenignore", []byte("# third party\nvendor/\n"), 0o600))
+	assert.NoError(t, os.WriteFile("tmp/features/api/.augurkenignore", []byte("generated\n*.draft.feature\n"), 0o600))
+
+	for _, f := range []string{
+		"tmp/features/vendor/file1.feature",
+		"tmp/features/api/generated/file2.feature",
+		"tmp/features/api/file3.feature",
+		"tmp/features/api/file4.draft.feature",
+		"tmp/features/api/file5.feature",
+		"tmp/features/ui/file6.feature",
+		"tmp/features/ui/file7.draft.feature",
+	} {
+		assert.NoError(t, os.WriteFile(f, content, 0o600))
+	}
+
+	type scenario struct {
+		fileManager FileManager
+		path        string
+		expected    []string
+	}
+
+	for _, s := range []scenario{
+		{
+			NewFileManager(2),
+			"tmp/features",
+			[]string{
+				"tmp/features/api/file3.feature",
+				"tmp/features/api/file5.feature",
+				// The rules of an ignore file don't apply to the sibling folders
+				"tmp/features/ui/file6.feature",
+				"tmp/features/ui/file7.draft.feature",
+			},
+		},
+		{
+			NewFileManager(2),
+			"tmp/features/api",
+			[]string{
+				"tmp/features/api/file3.feature",
+				"tmp/features/api/file5.feature",
+			},
+		},
+		{
+			NewFileManager(2).WithGitIgnore(),
+			"tmp/features",
+			[]string{
+				"tmp/features/api/file3.feature",
+				"tmp/features/api/file5.feature",
+			},
+		},
+		{
+			NewFileManager(2).WithExcludes("file5.feature").WithExcludes("tmp/features/ui"),
+			"tmp",
+			[]string{
+				"tmp/features/api/file3.feature",
+			},
+		},
+	} {
+		output := s.fileManager.Check(s.path)
+
+		assert.Equal(t, s.expected, paths(output))
+	}
+
+	// Cleanup
+	_ = os.RemoveAll("tmp/")
+}
+
+func TestFileManagerCache(t *testing.T) {
+	content := []byte(`Feature: test
+
+  Scenario: scenario
+    Given whatever
+`)
+
+	assert.NoError(t, os.RemoveAll("tmp"))
+	assert.NoError(t, os.MkdirAll("tmp", 0o777))
+	assert.NoError(t, os.WriteFile("tmp/file1.feature", content, 0o600))
+	assert.NoError(t, os.WriteFile("tmp/file2.feature", []byte("Feature: test\n\nScenario: scenario\n"), 0o600))
+
+	f := NewFileManager(2).WithCache("tmp/.augurken-cache")
+	output := f.Check("tmp")
+
+	assert.Equal(t, []Status{StatusUnchanged, StatusUnformatted}, []Status{output[0].Status, output[1].Status})
+	assert.False(t, output[0].Cached)
+
+	detected := output[0]
+
+	b, err := os.ReadFile("tmp/.augurken-cache")
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"version":"local"`)
+	assert.Contains(t, string(b), absPath(t, "tmp/file1.feature"))
+	assert.NotContains(t, string(b), absPath(t, "tmp/file2.feature"))
+
+	// Files found in the cache are not parsed, what was detected in their content comes from the cache
+	output = f.Check("tmp")
+
+	assert.Equal(t, []Status{StatusUnchanged, StatusUnformatted}, []Status{output[0].Status, output[1].Status})
+	assert.True(t, output[0].Cached)
+	assert.False(t, output[1].Cached)
+	assert.Equal(
+		t,
+		[]any{detected.Encoding, detected.Confidence, detected.EOL, detected.BOM},
+		[]any{output[0].Encoding, output[0].Confidence, output[0].EOL, output[0].BOM},
+	)
+	assert.Equal(t, "UTF-8", output[0].Encoding)
+	assert.Equal(t, "lf", output[0].EOL)
+
+	// Another content, other options or another version of augurken are not found in the cache
+	assert.NoError(t, os.WriteFile("tmp/file1.feature", append(content, "    Then whatever\n"...), 0o600))
+	assert.False(t, f.Check("tmp/file1.feature")[0].Cached)
+	assert.True(t, f.Check("tmp/file1.feature")[0].Cached)
+	assert.False(t, f.WithOptions(Options{Indent: 2}).Check("tmp/file1.feature")[0].Cached)
+
+	b, err = os.ReadFile("tmp/.augurken-cache")
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile("tmp/.augurken-cache", bytes.Replace(b, []byte(`"local"`), []byte(`"0.0.1"`), 1), 0o600))
+	assert.False(t, f.Check("tmp/file1.feature")[0].Cached)
+
+	// Cleanup
+	_ = os.RemoveAll("tmp/")
+}
+
+func TestFileManagerGit(t *testing.T) {
+	formatted := "Feature: test\n\n  Scenario: scenario\n    Given whatever\n"
+	unformatted := "Feature: test\n\nScenario: scenario\nGiven whatever\n"
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.RemoveAll("tmp"))
+	assert.NoError(t, os.MkdirAll("tmp/repo/features", 0o777))
+	assert.NoError(t, os.Chdir("tmp/repo"))
+
+	defer func() {
+		assert.NoError(t, os.Chdir(wd))
+		// Cleanup
+		_ = os.RemoveAll("tmp/")
+	}()
+
+	runGit(t, "init", "--quiet")
+
+	for _, f := range []string{"features/file1.feature", "features/file2.feature", "features/file3.feature"} {
+		assert.NoError(t, os.WriteFile(f, []byte(formatted), 0o600))
+	}
+
+	runGit(t, "add", ".")
+	runGit(t, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "init")
+
+	assert.NoError(t, os.WriteFile("features/file1.feature", []byte(unformatted), 0o600))
+	assert.NoError(t, os.WriteFile("features/file4.feature", []byte(unformatted), 0o600))
+	assert.NoError(t, os.WriteFile("features/file4.txt", []byte(unformatted), 0o600))
+
+	output := NewFileManager(2).WithChangedSince("HEAD").Check()
+
+	assert.Equal(t, []string{"features/file1.feature", "features/file4.feature"}, paths(output))
+	assert.Equal(t, Summary{Files: 2, Unformatted: 2}, output.Summary())
+
+	output = NewFileManager(2).WithChangedSince("HEAD").Check("features/file4.feature")
+
+	assert.Equal(t, []string{"features/file4.feature"}, paths(output))
+
+	// A file with unstaged changes is formatted in the index only
+	assert.NoError(t, os.WriteFile("features/file2.feature", []byte(unformatted), 0o600))
+	runGit(t, "add", "features/file1.feature", "features/file2.feature")
+	assert.NoError(t, os.WriteFile("features/file2.feature", []byte(unformatted+"Then whatever\n"), 0o600))
+
+	output = NewFileManager(2).WithStaged().Check(".")
+
+	assert.Equal(t, []string{"features/file1.feature", "features/file2.feature"}, paths(output))
+	assert.Equal(t, Summary{Files: 2, Unformatted: 2}, output.Summary())
+
+	output = NewFileManager(2).WithStaged().FormatAndReplace(".")
+
+	assertNoErrors(t, output)
+	assert.Equal(t, Summary{Files: 2, Reformatted: 2}, output.Summary())
+
+	for file, content := range map[string]string{
+		":features/file1.feature": formatted,
+		":features/file2.feature": formatted,
+	} {
+		assert.EqualValues(t, content, runGit(t, "show", file))
+	}
+
+	b, err := os.ReadFile("features/file1.feature")
+	assert.NoError(t, err)
+	assert.EqualValues(t, formatted, b)
+
+	b, err = os.ReadFile("features/file2.feature")
+	assert.NoError(t, err)
+	assert.EqualValues(t, unformatted+"Then whatever\n", b)
+
+	// Files staged as formatted in HEAD are no longer staged
+	assert.Empty(t, NewFileManager(2).WithStaged().Check("."))
+
+	// Files listed by git are skipped like files found when walking folders, unless given explicitly
+	assert.NoError(t, os.MkdirAll("features/drafts", 0o777))
+	assert.NoError(t, os.WriteFile("features/drafts/file5.feature", []byte(unformatted), 0o600))
+	assert.NoError(t, os.WriteFile("features/file6.feature", []byte(unformatted), 0o600))
+	assert.NoError(t, os.WriteFile("features/file7.feature", []byte(unformatted), 0o600))
+	assert.NoError(t, os.WriteFile("features/.augurkenignore", []byte("drafts/\n"), 0o600))
+	assert.NoError(t, os.WriteFile(".augurken.yml", []byte("exclude:\n  - features/file7.feature\n"), 0o600))
+
+	output = NewFileManager(2).WithConfigFiles().WithChangedSince("HEAD").WithExcludes("file6.feature").Check()
+
+	assert.Equal(t, []string{"features/file2.feature", "features/file4.feature"}, paths(output))
+
+	output = NewFileManager(2).WithChangedSince("HEAD").WithExcludes("file6.feature").Check("features/file6.feature")
+
+	assert.Equal(t, []string{"features/file6.feature"}, paths(output))
+
+	runGit(t, "add", "features")
+
+	output = NewFileManager(2).WithConfigFiles().WithStaged().WithExcludes("file6.feature").Check()
+
+	assert.Equal(t, []string{"features/file2.feature", "features/file4.feature"}, paths(output))
+
+	// Files outside the working directory are listed relative to it
+	assert.NoError(t, os.MkdirAll("sub", 0o777))
+	assert.NoError(t, os.Chdir("sub"))
+
+	expected := []string{"../features/file2.feature", "../features/file4.feature", "../features/file6.feature"}
+
+	output = NewFileManager(2).WithConfigFiles().WithStaged().Check("..")
+
+	assert.Equal(t, expected, paths(output))
+	assert.Equal(t, Summary{Files: 3, Unformatted: 3}, output.Summary())
+
+	// Patches are applied from the root of the repository
+	assert.True(t, strings.HasPrefix(output[0].Diff(), `diff --git a/features/file2.feature b/features/file2.feature
+--- a/features/file2.feature
++++ b/features/file2.feature
+`))
+
+	output = NewFileManager(2).WithConfigFiles().WithChangedSince("HEAD").Check("..")
+
+	assert.Equal(t, expected, paths(output))
+	assert.Equal(t, Summary{Files: 3, Unformatted: 3}, output.Summary())
+	assert.NoError(t, os.Chdir(".."))
+
+	assert.EqualError(t, NewFileManager(2).WithChangedSince("--output=x").Check(".")[0].Err, `invalid git ref "--output=x"`)
+}
+
+func TestFileManagerWatch(t *testing.T) {
+	formatted := "Feature: test\n\n  Scenario: scenario\n    Given whatever\n"
+	unformatted := "Feature: test\n\nScenario: scenario\nGiven whatever\n"
+
+	assert.NoError(t, os.RemoveAll("tmp"))
+	assert.NoError(t, os.MkdirAll("tmp/vendor", 0o777))
+	assert.NoError(t, os.WriteFile("tmp/.augurkenignore", []byte("vendor/\n"), 0o600))
+	assert.NoError(t, os.WriteFile("tmp/file1.feature", []byte(unformatted), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results := make(chan Result, 10)
+	done := make(chan error)
+
+	go func() {
+		done <- NewFileManager(2).Watch(ctx, []string{"tmp"}, func(result Result) { results <- result })
+	}()
+
+	next := func() Result {
+		select {
+		case result := <-results:
+			return result
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "A file should have been formatted")
+
+			return Result{}
+		}
+	}
+
+	// A file is written until the watcher, started in the background, formats it
+	assert.Eventually(t, func() bool {
+		select {
+		case result := <-results:
+			return result.Path == filepath.Join("tmp", "file0.feature")
+		default:
+			assert.NoError(t, os.WriteFile("tmp/file0.feature", []byte(unformatted), 0o600))
+
+			return false
 		}
+	}, 5*time.Second, 3*watchDebounce)
+
+	assert.NoError(t, os.WriteFile("tmp/vendor/file2.feature", []byte(unformatted), 0o600))
+	assert.NoError(t, os.MkdirAll("tmp/sub", 0o777))
+	assert.NoError(t, os.WriteFile("tmp/sub/file3.feature", []byte(unformatted), 0o600))
+
+	result := next()
+	assert.Equal(t, filepath.Join("tmp", "sub", "file3.feature"), result.Path)
+	assert.Equal(t, StatusReformatted, result.Status)
+
+	assert.NoError(t, os.WriteFile("tmp/file1.feature", []byte(unformatted+"Then whatever\n"), 0o600))
+
+	result = next()
+	assert.Equal(t, filepath.Join("tmp", "file1.feature"), result.Path)
+	assert.Equal(t, StatusReformatted, result.Status)
+
+	// The rewrites of formatted files are not formatted again
+	assert.Never(t, func() bool { return len(results) > 0 }, 5*watchDebounce, watchDebounce/10)
+
+	// Once its rewrite is skipped, a formatted file saved again is processed
+	assert.NoError(t, os.WriteFile("tmp/file1.feature", []byte(formatted+"    Then whatever\n"), 0o600))
+
+	result = next()
+	assert.Equal(t, filepath.Join("tmp", "file1.feature"), result.Path)
+	assert.Equal(t, StatusUnchanged, result.Status)
+
+	cancel()
+	assert.NoError(t, <-done)
+
+	for file, content := range map[string]string{
+		"tmp/file0.feature":        formatted,
+		"tmp/file1.feature":        formatted + "    Then whatever\n",
+		"tmp/vendor/file2.feature": unformatted,
+		"tmp/sub/file3.feature":    formatted,
+	} {
+		b, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		assert.EqualValues(t, content, b)
 	}
+
+	// Cleanup
+	_ = os.RemoveAll("tmp/")
+}
+
+func TestFileManagerFormatStream(t *testing.T) {
+	var out bytes.Buffer
+
+	f := NewFileManager(2)
+	output := f.FormatStream("login.feature", strings.NewReader(`Feature: test
+
+Scenario:            scenario1
+  Given       whatever
+`), &out)
+
+	assert.NoError(t, output.Err)
+	assert.Equal(t, StatusReformatted, output.Status)
+	assert.EqualValues(t, `Feature: test
+
+  Scenario: scenario1
+    Given whatever
+`, out.String())
+
+	out.Reset()
+	output = f.FormatStream("login.feature", strings.NewReader("whatever\n"), &out)
+
+	assert.Equal(t, StatusParseError, output.Status)
+	assert.EqualError(t, output.Err, `an error occurred with file "login.feature" : Parser errors:
+(1:1): expected: #EOF, #Language, #TagLine, #FeatureLine, #Comment, #Empty, got 'whatever'`)
+	assert.Empty(t, out.String())
+}
+
+func TestFileManagerCheckStream(t *testing.T) {
+	f := NewFileManager(2)
+	output := f.CheckStream("login.feature", strings.NewReader(`Feature: test
+
+  Scenario: scenario1
+    Given whatever
+`))
+
+	assert.NoError(t, output.Err)
+	assert.Equal(t, StatusUnchanged, output.Status)
+
+	output = f.CheckStream("login.feature", strings.NewReader(`Feature: test
+
+Scenario: scenario1
+`))
+
+	assert.Equal(t, StatusUnformatted, output.Status)
+	assert.EqualError(t, output.Err, `an error occurred with file "login.feature" : file is not properly formatted`)
+}
+
+func absPath(t *testing.T, path string) string {
+	abs, err := filepath.Abs(path)
+	assert.NoError(t, err)
+
+	return abs
+}
+
+func assertNoErrors(t *testing.T, results Results) {
+	for _, result := range results {
+		if result.Err != nil {
+			assert.Fail(t, "An error is not expected.", result.Err)
+		}
+	}
+}
+
+func runGit(t *testing.T, args ...string) string {
+	out, err := exec.Command("git", args...).CombinedOutput()
+	assert.NoError(t, err, string(out))
+
+	return string(out)
+}
+
+// diffPrefix returns the working directory relative to the root of its git repository, as it starts the paths
+// in the headers of diffs
+func diffPrefix(t *testing.T) string {
+	t.Helper()
+
+	prefix, err := exec.Command("git", "rev-parse", "--show-prefix").Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(prefix))
+}
+
+func paths(results Results) []string {
+	var paths []string
+	for _, result := range results {
+		paths = append(paths, result.Path)
+	}
+
+	return paths
 }
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package formatter
+
+import "os"
+
+// copyOwner does nothing, files have no owner and group to carry over on this system
+func copyOwner(_ string, _ os.FileInfo) {}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package formatter
+
+import (
+	"os"
+	"syscall"
+)
+
+// copyOwner gives a file the owner and group of another one when possible. Only a privileged user can give
+// a file to another user, a file keeps the owner of the process writing it otherwise
+func copyOwner(file string, info os.FileInfo) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		_ = os.Chown(file, int(stat.Uid), int(stat.Gid))
+	}
+}
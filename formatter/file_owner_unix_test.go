@@ -0,0 +1,37 @@
+//go:build unix
+
+package formatter
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileManagerFormatAndReplaceKeepsOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("only a privileged user can give a file to another user")
+	}
+
+	assert.NoError(t, os.RemoveAll("tmp"))
+	assert.NoError(t, os.MkdirAll("tmp", 0o777))
+	assert.NoError(t, os.WriteFile("tmp/file1.feature", []byte("Feature: test\n\nScenario: scenario\n"), 0o600))
+	assert.NoError(t, os.Chown("tmp/file1.feature", 1234, 5678))
+
+	output := NewFileManager(2).FormatAndReplace("tmp/file1.feature")
+
+	assertNoErrors(t, output)
+	assert.Equal(t, Summary{Files: 1, Reformatted: 1}, output.Summary())
+
+	info, err := os.Stat("tmp/file1.feature")
+	assert.NoError(t, err)
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	assert.True(t, ok)
+	assert.Equal(t, []uint32{1234, 5678}, []uint32{stat.Uid, stat.Gid})
+
+	// Cleanup
+	_ = os.RemoveAll("tmp/")
+}
@@ -3,6 +3,7 @@ package formatter
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -12,7 +13,8 @@ import (
 	augurkenjson "github.com/judimator/augurken/json"
 )
 
-func format(token *token, indent int) []byte {
+func format(token *token, options Options) ([]byte, []Diagnostic) {
+	indent := options.Indent
 	paddings := map[gherkin.TokenType]int{
 		gherkin.TokenTypeFeatureLine:        0,
 		gherkin.TokenTypeBackgroundLine:     indent,
@@ -24,6 +26,10 @@ func format(token *token, indent int) []byte {
 		gherkin.TokenTypeTableRow:           3 * indent,
 	}
 
+	extractTableRows := func(values []*gherkin.Token) []string {
+		return extractTableRowsAndComments(values, options.CompactTableJSON)
+	}
+
 	formats := map[gherkin.TokenType]func(values []*gherkin.Token) []string{
 		gherkin.TokenTypeFeatureLine:        extractKeywordAndTextSeparatedWithAColon,
 		gherkin.TokenTypeBackgroundLine:     extractKeywordAndTextSeparatedWithAColon,
@@ -31,11 +37,11 @@ func format(token *token, indent int) []byte {
 		gherkin.TokenTypeExamplesLine:       extractKeywordAndTextSeparatedWithAColon,
 		gherkin.TokenTypeComment:            extractTokensText,
 		gherkin.TokenTypeTagLine:            extractTokensItemsText,
-		gherkin.TokenTypeDocStringSeparator: extractKeyword,
+		gherkin.TokenTypeDocStringSeparator: extractTokensKeywordAndText,
 		gherkin.TokenTypeRuleLine:           extractKeywordAndTextSeparatedWithAColon,
 		gherkin.TokenTypeOther:              extractTokensText,
 		gherkin.TokenTypeStepLine:           extractTokensKeywordAndText,
-		gherkin.TokenTypeTableRow:           extractTableRowsAndComments,
+		gherkin.TokenTypeTableRow:           extractTableRows,
 		gherkin.TokenTypeEmpty:              extractTokensItemsText,
 	}
 
@@ -44,6 +50,7 @@ func format(token *token, indent int) []byte {
 	var (
 		document    []string
 		accumulator []*gherkin.Token
+		diagnostics []Diagnostic
 	)
 
 	for tok := token; tok != nil; tok = tok.nex {
@@ -84,34 +91,25 @@ func format(token *token, indent int) []byte {
 			lines = trimLinesSpace(lines)
 		case gherkin.TokenTypeTagLine:
 			padding = getTagOrCommentPadding(paddings, indent, tok)
-		case gherkin.TokenTypeDocStringSeparator:
-			lines = extractKeyword(tok.values)
 		case gherkin.TokenTypeOther:
-			if isDescriptionFeature(tok) {
+			switch {
+			case isDescriptionFeature(tok):
 				padding = indent
-			} else if tok.prev.kind == gherkin.TokenTypeDocStringSeparator &&
-				tok.nex.kind == gherkin.TokenTypeDocStringSeparator {
-				var buffer bytes.Buffer
-
-				// Transform into string and get bytes
-				source := []byte(strings.Join(lines, " "))
-				prefixSpace := strings.Repeat(" ", padding)
-				indentSpace := strings.Repeat(" ", indent)
-
-				// TODO: Handle json error and print col and line
-				if ok := augurkenjson.Valid(source); ok {
-					_ = augurkenjson.Indent(&buffer, source, prefixSpace, indentSpace)
-					lines = []string{buffer.String()}
+				lines = trimLinesSpace(lines)
+			case isDocString(tok):
+				var diagnostic *Diagnostic
+				if lines, diagnostic = formatDocString(tok, lines, padding, options); diagnostic != nil {
+					diagnostics = append(diagnostics, *diagnostic)
 				}
+			default:
+				lines = trimLinesSpace(lines)
 			}
-
-			lines = trimLinesSpace(lines)
 		}
 
 		document = append(document, trimExtraTrailingSpace(indentStrings(padding, lines))...)
 	}
 
-	return []byte(strings.Join(document, "\n") + "\n")
+	return []byte(strings.Join(document, "\n") + "\n"), diagnostics
 }
 
 func getTagOrCommentPadding(paddings map[gherkin.TokenType]int, indent int, tok *token) int {
@@ -148,6 +146,107 @@ func isDescriptionFeature(tok *token) bool {
 	return false
 }
 
+func isDocString(tok *token) bool {
+	return tok.prev != nil && tok.prev.kind == gherkin.TokenTypeDocStringSeparator &&
+		tok.nex != nil && tok.nex.kind == gherkin.TokenTypeDocStringSeparator
+}
+
+// formatDocString formats a doc string content as JSON when it applies. Any other content is kept as is:
+// the parser already strips the indentation of the opening separator, so lines only keep their indentation
+// relative to it and the whole block is shifted to the doc string padding afterwards.
+// A diagnostic is returned when the content should be JSON but is not valid
+func formatDocString(tok *token, lines []string, padding int, options Options) ([]string, *Diagnostic) {
+	var buffer bytes.Buffer
+
+	// Transform into string and get bytes
+	source := []byte(strings.Join(lines, " "))
+	prefixSpace := strings.Repeat(" ", padding)
+	indentSpace := strings.Repeat(" ", options.Indent)
+
+	isJSON, declared := isJSONDocString(getDocStringMediaType(tok), source, options)
+	if !isJSON || isPlaceholder(source) {
+		return lines, nil
+	}
+
+	if err := augurkenjson.Validate(source); err != nil {
+		// A content only guessed to be JSON may be prose, it is left untouched without warning
+		var syntaxError *augurkenjson.SyntaxError
+		if !declared || !errors.As(err, &syntaxError) {
+			return lines, nil
+		}
+
+		return lines, getDocStringDiagnostic(tok, lines, syntaxError)
+	}
+
+	_ = augurkenjson.Indent(&buffer, source, prefixSpace, indentSpace)
+
+	return trimLinesSpace([]string{buffer.String()}), nil
+}
+
+// getDocStringDiagnostic locates a JSON syntax error in the feature file. The offset is relative
+// to the doc string lines joined with a space, and those lines are relative to the opening separator
+func getDocStringDiagnostic(tok *token, lines []string, err *augurkenjson.SyntaxError) *Diagnostic {
+	separators := tok.prev.values
+	separatorColumn := separators[len(separators)-1].Location.Column
+	offset := int(err.Offset) - 1
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	line := 0
+	for line < len(lines)-1 && offset > len(lines[line]) {
+		offset -= len(lines[line]) + 1
+		line++
+	}
+
+	return &Diagnostic{
+		Line:    tok.values[line].Location.Line,
+		Column:  separatorColumn + offset,
+		Message: err.Error(),
+	}
+}
+
+// getDocStringMediaType returns the media type declared after the opening separator of a doc string, if any
+func getDocStringMediaType(tok *token) string {
+	separators := tok.prev.values
+
+	return strings.TrimSpace(separators[len(separators)-1].Text)
+}
+
+// isJSONDocString tells whether a doc string content must be handled as JSON. It is the case when
+// a JSON formatter is configured for its media type, when its media type declares JSON, or when no media
+// type is given and the content looks like a JSON object or array. Any other media type leaves the content untouched.
+// It also tells whether JSON is declared by the media type or the configuration rather than guessed from the content
+func isJSONDocString(mediaType string, source []byte, options Options) (bool, bool) {
+	if formatter, ok := options.docStringFormatter(mediaType); ok {
+		return formatter == DocStringFormatterJSON, true
+	}
+
+	if mediaType == "" {
+		trimmed := bytes.TrimSpace(source)
+
+		return options.DetectJSON && len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '['), false
+	}
+
+	return isJSONMediaType(mediaType), true
+}
+
+// isPlaceholder tells whether a content is only a scenario outline placeholder like `<data>`
+func isPlaceholder(source []byte) bool {
+	trimmed := bytes.TrimSpace(source)
+
+	return len(trimmed) > 1 && trimmed[0] == '<' && trimmed[len(trimmed)-1] == '>' &&
+		bytes.IndexAny(trimmed[1:len(trimmed)-1], "<>") == -1
+}
+
+// isJSONMediaType matches media types like `json`, `application/json` or `application/ld+json`
+func isJSONMediaType(mediaType string) bool {
+	mediaType = strings.ToLower(mediaType)
+
+	return mediaType == "json" || strings.HasSuffix(mediaType, "/json") || strings.HasSuffix(mediaType, "+json")
+}
+
 func trimLinesSpace(lines []string) []string {
 	content := []string{}
 	for _, line := range lines {
@@ -217,16 +316,7 @@ func extractKeywordAndTextSeparatedWithAColon(tokens []*gherkin.Token) []string
 	return content
 }
 
-func extractKeyword(tokens []*gherkin.Token) []string {
-	content := []string{}
-	for _, t := range tokens {
-		content = append(content, t.Keyword)
-	}
-
-	return content
-}
-
-func extractTableRowsAndComments(tokens []*gherkin.Token) []string {
+func extractTableRowsAndComments(tokens []*gherkin.Token, compactJSON bool) []string {
 	type tableElement struct {
 		content []string
 		kind    gherkin.TokenType
@@ -248,7 +338,7 @@ func extractTableRowsAndComments(tokens []*gherkin.Token) []string {
 				var text string
 
 				source := []byte(data.Text)
-				if ok := json.Valid(source); ok {
+				if ok := compactJSON && json.Valid(source); ok {
 					var buffer bytes.Buffer
 					_ = json.Compact(&buffer, source)
 					text = buffer.String()
@@ -0,0 +1,150 @@
+package formatter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	mpath "path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// gitIndexMutex serializes the updates of the index, git fails when the index is locked by another update
+var gitIndexMutex sync.Mutex
+
+// gitChangedFiles lists the feature files matching a pathspec that changed in the working tree since a ref,
+// untracked files included. Paths are relative to the working directory
+func gitChangedFiles(ref string, pathspec string) ([]string, error) {
+	if strings.HasPrefix(ref, "-") {
+		return []string{}, fmt.Errorf("invalid git ref %q", ref)
+	}
+
+	changed, err := git(nil, "diff", "--name-only", "-z", "--diff-filter=ACMR", ref, "--", pathspec)
+	if err != nil {
+		return []string{}, err
+	}
+
+	untracked, err := git(nil, "ls-files", "--others", "--exclude-standard", "--full-name", "-z", "--", pathspec)
+	if err != nil {
+		return []string{}, err
+	}
+
+	return gitFeatureFiles(append(changed, untracked...))
+}
+
+// gitStagedFiles lists the feature files matching a pathspec that are staged in the index.
+// Paths are relative to the working directory
+func gitStagedFiles(pathspec string) ([]string, error) {
+	staged, err := git(nil, "diff", "--cached", "--name-only", "-z", "--diff-filter=ACMR", "--", pathspec)
+	if err != nil {
+		return []string{}, err
+	}
+
+	return gitFeatureFiles(staged)
+}
+
+// gitStagedContent returns the content of a file staged in the index
+func gitStagedContent(file string) ([]byte, error) {
+	return git(nil, "show", ":./"+filepath.ToSlash(file))
+}
+
+// gitStage replaces the content of a file staged in the index, keeping its mode
+func gitStage(file string, content []byte) error {
+	entry, err := git(nil, "ls-files", "--stage", "--full-name", "-z", "--", file)
+	if err != nil {
+		return err
+	}
+
+	// An entry is made of "<mode> <object> <stage>\t<path>"
+	info, path, found := strings.Cut(strings.TrimSuffix(string(entry), "\x00"), "\t")
+	if !found {
+		return fmt.Errorf("%s is not staged", file)
+	}
+
+	object, err := git(content, "hash-object", "-w", "--no-filters", "--stdin")
+	if err != nil {
+		return err
+	}
+
+	mode, _, _ := strings.Cut(info, " ")
+
+	gitIndexMutex.Lock()
+	defer gitIndexMutex.Unlock()
+
+	_, err = git(nil, "update-index", "--cacheinfo", mode+","+strings.TrimSpace(string(object))+","+path)
+
+	return err
+}
+
+// gitTopLevel returns the absolute path of the root of the git repository containing the working directory
+func gitTopLevel() (string, error) {
+	top, err := git(nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.FromSlash(strings.TrimSpace(string(top))), nil
+}
+
+// gitFeatureFiles returns the sorted feature files of a NUL separated list of paths relative to the root
+// of the repository. Files are returned relative to the working directory, they may be outside of it
+func gitFeatureFiles(output []byte) ([]string, error) {
+	top, err := gitTopLevel()
+	if err != nil {
+		return []string{}, err
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return []string{}, err
+	}
+
+	// git resolves symbolic links in the path of the repository
+	if resolved, err := filepath.EvalSymlinks(wd); err == nil {
+		wd = resolved
+	}
+
+	files := []string{}
+
+	for _, file := range strings.Split(string(output), "\x00") {
+		if mpath.Ext(file) != ".feature" {
+			continue
+		}
+
+		rel, err := filepath.Rel(wd, filepath.Join(top, filepath.FromSlash(file)))
+		if err != nil {
+			return []string{}, err
+		}
+
+		files = append(files, rel)
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// git runs a git command in the working directory and returns its output
+func git(stdin []byte, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("git", args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitError *exec.ExitError
+		if errors.As(err, &exitError) && stderr.Len() > 0 {
+			return nil, fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+		}
+
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,167 @@
+package formatter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	ignore "github.com/sabhiram/go-gitignore"
+)
+
+const (
+	// ignoreFileName is the file listing the files to skip when walking folders, with the gitignore syntax
+	ignoreFileName = ".augurkenignore"
+	// gitIgnoreFileName is the file listing the files ignored by git
+	gitIgnoreFileName = ".gitignore"
+)
+
+// ignoreRules holds patterns with the gitignore syntax, relative to a folder
+type ignoreRules struct {
+	dir     string
+	matcher *ignore.GitIgnore
+}
+
+// matches tells whether an absolute path is matched by the patterns
+func (r ignoreRules) matches(path string, isDir bool) bool {
+	rel, err := filepath.Rel(r.dir, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+
+	rel = filepath.ToSlash(rel)
+	if isDir {
+		rel += "/"
+	}
+
+	return r.matcher.MatchesPath(rel)
+}
+
+// ignorer tells which files and folders must be skipped when walking a folder
+type ignorer struct {
+	fileNames []string
+	// excludes are the patterns given along with the files, relative to the working directory
+	excludes *ignoreRules
+	// rules maps each folder read to the rules of its ignore files, which only apply to the content of the folder
+	rules map[string][]ignoreRules
+}
+
+// newIgnorer creates an ignorer reading ignore files from the ancestors of a folder. Ancestors are read
+// up to the root of the git repository containing the folder, or up to the filesystem root.
+// Excludes are patterns relative to the working directory
+func newIgnorer(root string, excludes []string, respectGitIgnore bool) (*ignorer, error) {
+	i := &ignorer{fileNames: []string{ignoreFileName}, rules: map[string][]ignoreRules{}}
+
+	if respectGitIgnore {
+		i.fileNames = append(i.fileNames, gitIgnoreFileName)
+	}
+
+	if len(excludes) > 0 {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+
+		i.excludes = &ignoreRules{dir: wd, matcher: ignore.CompileIgnoreLines(excludes...)}
+	}
+
+	for dir := root; !isGitRoot(dir); {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+
+		if err := i.read(parent); err != nil {
+			return nil, err
+		}
+
+		dir = parent
+	}
+
+	return i, nil
+}
+
+// read loads the ignore files of a folder once, they apply to everything in the folder
+func (i *ignorer) read(dir string) error {
+	if _, ok := i.rules[dir]; ok {
+		return nil
+	}
+
+	var rules []ignoreRules
+
+	for _, name := range i.fileNames {
+		file := filepath.Join(dir, name)
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			continue
+		}
+
+		matcher, err := ignore.CompileIgnoreFile(file)
+		if err != nil {
+			return err
+		}
+
+		rules = append(rules, ignoreRules{dir: dir, matcher: matcher})
+	}
+
+	i.rules[dir] = rules
+
+	return nil
+}
+
+// isIgnored tells whether an absolute path must be skipped. Only the rules of the folders containing
+// the path are matched
+func (i *ignorer) isIgnored(path string, isDir bool) bool {
+	if i.excludes != nil && i.excludes.matches(path, isDir) {
+		return true
+	}
+
+	for dir := filepath.Dir(path); ; {
+		for _, rules := range i.rules[dir] {
+			if rules.matches(path, isDir) {
+				return true
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+
+		dir = parent
+	}
+}
+
+// isIgnoredBelow tells whether an absolute path found below a root folder must be skipped, as if the root
+// was walked: the ignore files of the folders in between are read and the path is skipped when one of
+// these folders is ignored
+func (i *ignorer) isIgnoredBelow(root string, path string, isDir bool) (bool, error) {
+	rel, err := filepath.Rel(root, filepath.Dir(path))
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return i.isIgnored(path, isDir), nil
+	}
+
+	dir := root
+	if err := i.read(dir); err != nil {
+		return false, err
+	}
+
+	if rel != "." {
+		for _, name := range strings.Split(rel, string(filepath.Separator)) {
+			dir = filepath.Join(dir, name)
+			if i.isIgnored(dir, true) {
+				return true, nil
+			}
+
+			if err := i.read(dir); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	return i.isIgnored(path, isDir), nil
+}
+
+func isGitRoot(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+
+	return err == nil
+}
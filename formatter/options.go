@@ -0,0 +1,158 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// Doc string formatters that can be applied to a doc string content depending on its media type
+const (
+	DocStringFormatterJSON = "json"
+	DocStringFormatterNone = "none"
+)
+
+// Line ending policies applied to formatted contents
+const (
+	// EOLPreserve keeps the line ending found first in the content, LF is used when there is none
+	EOLPreserve = "preserve"
+	// EOLLF ends lines with LF
+	EOLLF = "lf"
+	// EOLCRLF ends lines with CRLF
+	EOLCRLF = "crlf"
+	// EOLNative ends lines with CRLF on Windows and LF elsewhere
+	EOLNative = "native"
+)
+
+// BOM policies applied to formatted contents
+const (
+	// BOMKeep keeps the BOM of the content, if any
+	BOMKeep = "keep"
+	// BOMStrip removes the BOM of the content
+	BOMStrip = "strip"
+	// BOMAdd starts the content with a BOM, a UTF-8 one when it has none
+	BOMAdd = "add"
+)
+
+// Final newline policies applied to formatted contents
+const (
+	// FinalNewlineKeep ends the content with a newline only when it already ends with one
+	FinalNewlineKeep = "keep"
+	// FinalNewlineStrip removes the newline ending the content
+	FinalNewlineStrip = "strip"
+	// FinalNewlineAdd ends the content with a newline
+	FinalNewlineAdd = "add"
+)
+
+// Options holds every setting used to format a feature
+type Options struct {
+	// Indent is the number of spaces of an indentation level
+	Indent int
+	// DocStringFormatters maps a doc string media type to the formatter applied to its content.
+	// It takes precedence over the formatter guessed from the media type
+	DocStringFormatters map[string]string
+	// DetectJSON formats doc strings without media type as JSON when they contain a JSON object or array
+	DetectJSON bool
+	// CompactTableJSON compacts JSON found in table cells
+	CompactTableJSON bool
+	// Encoding is the charset of the content, it is detected when empty
+	Encoding string
+	// MinConfidence is the minimum confidence, from 0 to 100, of a detected charset. A content whose charset
+	// is detected with a lower confidence is not formatted
+	MinConfidence int
+	// PreserveEncoding writes the formatted content in the charset of the content rather than in UTF-8
+	PreserveEncoding bool
+	// EOL is the line ending policy: lf, crlf, native or preserve. An empty policy preserves line endings
+	EOL string
+	// BOM is the BOM policy: keep, strip or add. An empty policy keeps the BOM
+	BOM string
+	// FinalNewline is the final newline policy: keep, strip or add. An empty policy adds a final newline
+	FinalNewline string
+}
+
+// DefaultOptions returns the options used when nothing else is configured
+func DefaultOptions() Options {
+	return Options{
+		Indent:           2,
+		DetectJSON:       true,
+		CompactTableJSON: true,
+		EOL:              EOLPreserve,
+		BOM:              BOMKeep,
+		FinalNewline:     FinalNewlineAdd,
+	}
+}
+
+// validate checks that all settings have a supported value
+func (o Options) validate() error {
+	// An indentation of zero would flatten features, which zero-value options would silently do
+	if o.Indent < 1 {
+		return fmt.Errorf("indent must be at least 1, got %d", o.Indent)
+	}
+
+	if o.Encoding != "" {
+		if e, _ := charset.Lookup(o.Encoding); e == nil {
+			return fmt.Errorf(`unknown charset "%s"`, o.Encoding)
+		}
+	}
+
+	if o.MinConfidence < 0 || o.MinConfidence > 100 {
+		return fmt.Errorf("min confidence must be between 0 and 100, got %d", o.MinConfidence)
+	}
+
+	switch o.EOL {
+	case "", EOLPreserve, EOLLF, EOLCRLF, EOLNative:
+	default:
+		return fmt.Errorf(
+			`unknown line ending policy "%s", expected "%s", "%s", "%s" or "%s"`,
+			o.EOL,
+			EOLLF,
+			EOLCRLF,
+			EOLNative,
+			EOLPreserve,
+		)
+	}
+
+	switch o.BOM {
+	case "", BOMKeep, BOMStrip, BOMAdd:
+	default:
+		return fmt.Errorf(`unknown BOM policy "%s", expected "%s", "%s" or "%s"`, o.BOM, BOMKeep, BOMStrip, BOMAdd)
+	}
+
+	switch o.FinalNewline {
+	case "", FinalNewlineKeep, FinalNewlineStrip, FinalNewlineAdd:
+	default:
+		return fmt.Errorf(
+			`unknown final newline policy "%s", expected "%s", "%s" or "%s"`,
+			o.FinalNewline,
+			FinalNewlineKeep,
+			FinalNewlineStrip,
+			FinalNewlineAdd,
+		)
+	}
+
+	for mediaType, formatter := range o.DocStringFormatters {
+		if formatter != DocStringFormatterJSON && formatter != DocStringFormatterNone {
+			return fmt.Errorf(
+				`unknown doc string formatter "%s" for media type "%s", expected "%s" or "%s"`,
+				formatter,
+				mediaType,
+				DocStringFormatterJSON,
+				DocStringFormatterNone,
+			)
+		}
+	}
+
+	return nil
+}
+
+// docStringFormatter returns the formatter configured for a media type, if any
+func (o Options) docStringFormatter(mediaType string) (string, bool) {
+	for m, formatter := range o.DocStringFormatters {
+		if strings.EqualFold(m, mediaType) {
+			return formatter, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,168 @@
+package formatter
+
+// Status is the outcome of processing a file
+type Status int
+
+const (
+	// StatusUnchanged means the file is already properly formatted
+	StatusUnchanged Status = iota
+	// StatusReformatted means the file was not properly formatted and its formatted content was written
+	StatusReformatted
+	// StatusUnformatted means the file is not properly formatted and was left as is
+	StatusUnformatted
+	// StatusParseError means the file is not a valid Gherkin feature
+	StatusParseError
+	// StatusIOError means the file could not be found, read, decoded or written, or its configuration is invalid
+	StatusIOError
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusUnchanged:
+		return "unchanged"
+	case StatusReformatted:
+		return "reformatted"
+	case StatusUnformatted:
+		return "unformatted"
+	case StatusParseError:
+		return "parse error"
+	case StatusIOError:
+		return "IO error"
+	default:
+		return "unknown"
+	}
+}
+
+// ID returns the identifier of a status in machine-readable reports, it doesn't change from a version to another
+func (s Status) ID() string {
+	switch s {
+	case StatusUnchanged:
+		return "unchanged"
+	case StatusReformatted:
+		return "reformatted"
+	case StatusUnformatted:
+		return "unformatted"
+	case StatusParseError:
+		return "parse-error"
+	case StatusIOError:
+		return "io-error"
+	default:
+		return "unknown"
+	}
+}
+
+// Result is the outcome of processing a file
+type Result struct {
+	// Path is the file processed, or the path or glob pattern that could not be expanded into files
+	Path   string
+	Status Status
+	// Original is the content read, before any decoding
+	Original []byte
+	// Formatted is the formatted content, it is empty when the file could not be formatted
+	Formatted []byte
+	// Encoding is the charset of the original content
+	Encoding string
+	// Confidence is the confidence, from 0 to 100, of the detected charset
+	Confidence int
+	// EncodingGiven tells whether the charset was given in the options rather than detected
+	EncodingGiven bool
+	// Converted tells whether the content was converted from its charset to UTF-8
+	Converted bool
+	// EOL is the line separator detected in the original content: lf, crlf, cr or an empty string for none
+	EOL string
+	// BOM tells whether the original content starts with a byte order mark
+	BOM bool
+	// Cached tells whether the file is known to be properly formatted by the cache, so it was not parsed.
+	// What was detected in its content is taken from the cache
+	Cached      bool
+	Diagnostics []Diagnostic
+	// Err describes why the file is not properly formatted or could not be processed, it is nil otherwise
+	Err error
+	// unprocessed tells whether the result doesn't concern a feature file, like a path that could not be
+	// expanded into files
+	unprocessed bool
+}
+
+// Failed tells whether the file is not properly formatted or could not be processed
+func (r Result) Failed() bool {
+	return r.Status == StatusUnformatted || r.Status == StatusParseError || r.Status == StatusIOError
+}
+
+// Diff returns a unified diff, in UTF-8, of the changes needed to format the file, or an empty string when
+// none is needed
+func (r Result) Diff() string {
+	if r.Status != StatusUnformatted && r.Status != StatusReformatted {
+		return ""
+	}
+
+	// Contents are diffed once decoded, the formatted content is already in UTF-8 when it was converted
+	formatted := r.Formatted
+	if !r.Converted {
+		formatted = decodeText(formatted, r.Encoding)
+	}
+
+	return unifiedDiff(r.Path, decodeText(r.Original, r.Encoding), formatted)
+}
+
+// fail returns a copy of the result failing with the given status and error
+func (r Result) fail(status Status, err error) Result {
+	r.Status = status
+	r.Err = ProcessFileError{Message: err.Error(), File: r.Path, Err: err}
+
+	return r
+}
+
+// Results are the outcomes of processing files, in path order
+type Results []Result
+
+// Summary counts the files of each status and the diagnostics reported
+type Summary struct {
+	Files       int
+	Unchanged   int
+	Reformatted int
+	Unformatted int
+	ParseErrors int
+	IOErrors    int
+	// OtherErrors counts the errors that don't concern a feature file, like a path that could not be expanded
+	// into files or a cache file that could not be written. They are not counted in Files
+	OtherErrors int
+	Diagnostics int
+}
+
+// Failures returns the number of files not properly formatted or that could not be processed,
+// along with the other errors
+func (s Summary) Failures() int {
+	return s.Unformatted + s.ParseErrors + s.IOErrors + s.OtherErrors
+}
+
+// Summary aggregates the results
+func (r Results) Summary() Summary {
+	var summary Summary
+
+	for _, result := range r {
+		if result.unprocessed {
+			summary.OtherErrors++
+
+			continue
+		}
+
+		summary.Files++
+
+		switch result.Status {
+		case StatusUnchanged:
+			summary.Unchanged++
+		case StatusReformatted:
+			summary.Reformatted++
+		case StatusUnformatted:
+			summary.Unformatted++
+		case StatusParseError:
+			summary.ParseErrors++
+		case StatusIOError:
+			summary.IOErrors++
+		}
+
+		summary.Diagnostics += len(result.Diagnostics)
+	}
+
+	return summary
+}
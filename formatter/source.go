@@ -0,0 +1,173 @@
+package formatter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/saintfish/chardet"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+)
+
+// FormatBytes formats a feature held in memory. Diagnostics, like invalid JSON in a doc string, are not
+// reported, an error is only returned when the options are invalid or the feature can't be decoded or parsed
+func FormatBytes(src []byte, opts Options) ([]byte, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	result := formatSource("", src, opts)
+
+	return result.Formatted, errors.Unwrap(result.Err)
+}
+
+// FormatReader formats a feature read from r and writes the result to w. Nothing is written when
+// the feature can't be formatted
+func FormatReader(r io.Reader, w io.Writer, opts Options) error {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	formatted, err := FormatBytes(src, opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(formatted)
+
+	return err
+}
+
+// formatSource formats a content along with the diagnostics found in it. The filename is only used
+// in diagnostics and errors. The status of a formatted content is left for the caller to set
+func formatSource(filename string, content []byte, options Options) Result {
+	var (
+		err error
+		// e is the charset the content is decoded from, it is nil when the content is formatted as is
+		e encoding.Encoding
+	)
+
+	result := Result{Path: filename, Original: content}
+
+	contentHelper := &ContentHelper{}
+	contentHelper.SetEOLPolicy(options.EOL)
+	contentHelper.SetBOMPolicy(options.BOM)
+	contentHelper.SetFinalNewlinePolicy(options.FinalNewline)
+	contentHelper.DetectSettings(content)
+
+	// A BOM gives the encoding of a content, the content helper decodes it and encodes it back unless the BOM
+	// is stripped
+	if result.Encoding = contentHelper.Encoding(); result.Encoding != "" {
+		result.Confidence = 100
+		result.Converted = contentHelper.ConvertsToUTF8()
+
+		if result.Converted && options.PreserveEncoding {
+			return result.fail(StatusIOError, fmt.Errorf(
+				"can't strip the BOM of a content written back in %s, its encoding would be lost",
+				result.Encoding,
+			))
+		}
+	} else {
+		result.Encoding, result.Confidence, err = detectEncoding(content, options)
+		if err != nil {
+			return result.fail(StatusIOError, err)
+		}
+
+		result.EncodingGiven = options.Encoding != ""
+
+		// The content is formatted in UTF-8
+		var name string
+
+		e, name = charset.Lookup(result.Encoding)
+		if e == nil {
+			return result.fail(StatusIOError, fmt.Errorf(`unknown charset "%s"`, result.Encoding))
+		}
+
+		if name == "utf-8" {
+			e = nil
+		} else {
+			content, err = e.NewDecoder().Bytes(content)
+			if err != nil {
+				return result.fail(StatusIOError, err)
+			}
+
+			contentHelper.DetectSettings(content)
+
+			// A UTF-8 BOM can't start a content written back in another charset
+			if options.PreserveEncoding && options.BOM == BOMAdd {
+				contentHelper.SetBOMPolicy(BOMKeep)
+			}
+		}
+	}
+
+	content = contentHelper.Prepare(content)
+	result.EOL = contentHelper.EOL()
+	result.BOM = contentHelper.HasBom()
+
+	token, err := parse(content)
+	if err != nil {
+		return result.fail(StatusParseError, err)
+	}
+
+	formatted, diagnostics := format(token, options)
+	formatted = contentHelper.Restore(formatted)
+
+	if line, column := contentHelper.MixedEOL(); line > 0 {
+		diagnostics = append(diagnostics, Diagnostic{
+			Line:    line,
+			Column:  column,
+			Message: "mixed line endings, converted to " + contentHelper.TargetEOL(),
+		})
+	}
+
+	switch {
+	case e == nil:
+	case options.PreserveEncoding:
+		formatted, err = e.NewEncoder().Bytes(formatted)
+		if err != nil {
+			return result.fail(StatusIOError, fmt.Errorf("can't encode the content in %s: %w", result.Encoding, err))
+		}
+	default:
+		result.Converted = true
+	}
+
+	for i := range diagnostics {
+		diagnostics[i].File = filename
+	}
+
+	result.Formatted = formatted
+	result.Diagnostics = diagnostics
+
+	return result
+}
+
+// detectEncoding returns the charset of a content along with the confidence, from 0 to 100, of the guess.
+// A content that is valid UTF-8 is not guessed, the charsets guessed for short contents are often wrong
+func detectEncoding(content []byte, options Options) (string, int, error) {
+	if options.Encoding != "" {
+		return options.Encoding, 100, nil
+	}
+
+	if utf8.Valid(content) {
+		return "UTF-8", 100, nil
+	}
+
+	detected, err := chardet.NewTextDetector().DetectBest(content)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if detected.Confidence < options.MinConfidence {
+		return detected.Charset, detected.Confidence, fmt.Errorf(
+			"charset %s detected with a confidence of %d%%, lower than %d%%, the charset must be given",
+			detected.Charset,
+			detected.Confidence,
+			options.MinConfidence,
+		)
+	}
+
+	return detected.Charset, detected.Confidence, nil
+}
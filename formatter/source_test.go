@@ -0,0 +1,340 @@
+package formatter
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatBytes(t *testing.T) {
+	src := []byte(`Feature: test
+
+Scenario:            scenario1
+  Given       whatever
+"""json
+{"key": "value"}
+"""
+`)
+
+	options := DefaultOptions()
+	options.Indent = 4
+
+	b, err := FormatBytes(src, options)
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, `Feature: test
+
+    Scenario: scenario1
+        Given whatever
+            """json
+            {
+                "key": "value"
+            }
+            """
+`, string(b))
+
+	options.DocStringFormatters = map[string]string{"json": DocStringFormatterNone}
+	b, err = FormatBytes(src, options)
+
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "            {\"key\": \"value\"}\n")
+
+	options.DocStringFormatters = map[string]string{"json": "xml"}
+	_, err = FormatBytes(src, options)
+
+	assert.EqualError(t, err, `unknown doc string formatter "xml" for media type "json", expected "json" or "none"`)
+
+	_, err = FormatBytes([]byte("whatever\n"), DefaultOptions())
+
+	assert.EqualError(t, err, `Parser errors:
+(1:1): expected: #EOF, #Language, #TagLine, #FeatureLine, #Comment, #Empty, got 'whatever'`)
+}
+
+func TestFormatReader(t *testing.T) {
+	var out bytes.Buffer
+
+	err := FormatReader(strings.NewReader("Feature: test\r\n\r\nScenario: scenario1\r\n"), &out, DefaultOptions())
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, "Feature: test\r\n\r\n  Scenario: scenario1\r\n", out.String())
+
+	out.Reset()
+	err = FormatReader(strings.NewReader("whatever\n"), &out, DefaultOptions())
+
+	assert.Error(t, err)
+	assert.Empty(t, out.String())
+}
+
+func TestFormatBytesEncoding(t *testing.T) {
+	src, err := os.ReadFile("features/iso-8859-1-encoding.input.feature")
+	assert.NoError(t, err)
+
+	expected, err := os.ReadFile("features/iso-8859-1-encoding.expected.feature")
+	assert.NoError(t, err)
+
+	options := DefaultOptions()
+	b, err := FormatBytes(src, options)
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, expected, b)
+
+	options.PreserveEncoding = true
+	b, err = FormatBytes(src, options)
+
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(b, []byte("Feature: a\xe4\xe1e\xe9o\xf3ou\xfcu\xdf\xe4\n  In order")))
+
+	options.MinConfidence = 100
+	_, err = FormatBytes(src, options)
+
+	assert.ErrorContains(t, err, "detected with a confidence of")
+
+	// A given charset is not detected
+	options.Encoding = "latin1"
+	b, err = FormatBytes([]byte("Feature: caf\xe9\n\nScenario: scenario\n"), options)
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, "Feature: caf\xe9\n\n  Scenario: scenario\n", b)
+
+	// A conversion to UTF-8 is not a diagnostic, it doesn't fail strict checks
+	result := formatSource("test.feature", []byte("Feature: caf\xe9\n"), Options{Indent: 2, Encoding: "latin1"})
+
+	assert.NoError(t, result.Err)
+	assert.Equal(t, []any{"latin1", true, true}, []any{result.Encoding, result.EncodingGiven, result.Converted})
+	assert.Empty(t, result.Diagnostics)
+
+	result = formatSource("test.feature", src, DefaultOptions())
+
+	assert.NoError(t, result.Err)
+	assert.Equal(t, []any{false, true}, []any{result.EncodingGiven, result.Converted})
+	assert.Empty(t, result.Diagnostics)
+
+	options.Encoding = "whatever"
+	_, err = FormatBytes(src, options)
+
+	assert.EqualError(t, err, `unknown charset "whatever"`)
+}
+
+func TestFormatBytesEOL(t *testing.T) {
+	src := []byte("Feature: test\r\n\r\nScenario: scenario1\r\n")
+
+	options := DefaultOptions()
+	b, err := FormatBytes(src, options)
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, "Feature: test\r\n\r\n  Scenario: scenario1\r\n", b)
+
+	options.EOL = EOLLF
+	b, err = FormatBytes(src, options)
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, "Feature: test\n\n  Scenario: scenario1\n", b)
+
+	options.EOL = EOLCRLF
+	b, err = FormatBytes([]byte("Feature: test\n\nScenario: scenario1\n"), options)
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, "Feature: test\r\n\r\n  Scenario: scenario1\r\n", b)
+
+	// A content without line ending gets LF line endings
+	options.EOL = EOLPreserve
+	b, err = FormatBytes([]byte("Feature: test"), options)
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, "Feature: test\n", b)
+
+	options.EOL = "whatever"
+	_, err = FormatBytes(src, options)
+
+	assert.EqualError(t, err, `unknown line ending policy "whatever", expected "lf", "crlf", "native" or "preserve"`)
+}
+
+func TestFormatSourceMixedEOL(t *testing.T) {
+	result := formatSource("test.feature", []byte("Feature: test\n\r\n  Scenario: scenario1\r\n"), DefaultOptions())
+
+	assert.NoError(t, result.Err)
+	assert.EqualValues(t, "Feature: test\n\n  Scenario: scenario1\n", result.Formatted)
+	assert.Equal(t, []Diagnostic{
+		{File: "test.feature", Line: 2, Column: 1, Message: "mixed line endings, converted to lf"},
+	}, result.Diagnostics)
+
+	// A CR that is not a line separator is part of its line, whatever the line ending policy
+	src := []byte("Feature: test\n\n  Scenario: scenario1\n    Given whatever\n      \"\"\"\n      a\rb\n      \"\"\"\n")
+
+	for policy, expected := range map[string]string{
+		EOLPreserve: string(src),
+		EOLLF:       string(src),
+		EOLCRLF:     strings.ReplaceAll(string(src), "\n", "\r\n"),
+	} {
+		options := DefaultOptions()
+		options.EOL = policy
+		result = formatSource("test.feature", src, options)
+
+		assert.NoError(t, result.Err, policy)
+		assert.EqualValues(t, expected, result.Formatted, policy)
+		assert.Empty(t, result.Diagnostics, policy)
+
+		result = formatSource("test.feature", []byte(expected), options)
+
+		assert.NoError(t, result.Err, policy)
+		assert.EqualValues(t, expected, result.Formatted, policy)
+		assert.Empty(t, result.Diagnostics, policy)
+	}
+
+	// CRs are line separators in a content without LF
+	result = formatSource("test.feature", []byte("Feature: test\r\rScenario: scenario1\r"), DefaultOptions())
+
+	assert.NoError(t, result.Err)
+	assert.EqualValues(t, "Feature: test\r\r  Scenario: scenario1\r", result.Formatted)
+	assert.Equal(t, "cr", result.EOL)
+
+	options := DefaultOptions()
+	options.EOL = EOLLF
+	result = check(formatSource("test.feature", []byte("Feature: test\r\n"), options))
+
+	assert.Equal(t, StatusUnformatted, result.Status)
+	assert.ErrorContains(t, result.Err, "file is not properly formatted, line endings are crlf instead of lf")
+}
+
+func TestFormatBytesUnicodeBOM(t *testing.T) {
+	src := "Feature: café\n\n  Scenario: scenario1\n"
+
+	for _, bom := range byteOrderMarks {
+		if bom.encoding == nil {
+			continue
+		}
+
+		encoded, err := bom.encoding.NewEncoder().Bytes([]byte(src))
+		assert.NoError(t, err)
+
+		encoded = append(append([]byte{}, bom.bom...), encoded...)
+
+		// A BOM is kept or added along with the encoding it gives, a content whose BOM is stripped is in UTF-8
+		for policy, expected := range map[string][]byte{
+			BOMKeep:  encoded,
+			BOMAdd:   encoded,
+			BOMStrip: []byte(src),
+		} {
+			options := DefaultOptions()
+			options.BOM = policy
+			result := formatSource("test.feature", encoded, options)
+
+			assert.NoError(t, result.Err, bom.name+" "+policy)
+			assert.EqualValues(t, expected, result.Formatted, bom.name+" "+policy)
+			assert.Equal(t, policy == BOMStrip, result.Converted, bom.name+" "+policy)
+		}
+
+		options := DefaultOptions()
+		options.BOM = BOMStrip
+		options.PreserveEncoding = true
+		_, err = FormatBytes(encoded, options)
+
+		assert.EqualError(
+			t,
+			err,
+			"can't strip the BOM of a content written back in "+bom.name+", its encoding would be lost",
+			bom.name,
+		)
+	}
+}
+
+func TestFormatSourceUnicodeRoundTrip(t *testing.T) {
+	src := "Feature: café\r\n\r\nScenario: scenario1\r\n"
+
+	for _, bom := range byteOrderMarks {
+		encoded := []byte(src)
+
+		if bom.encoding != nil {
+			var err error
+
+			encoded, err = bom.encoding.NewEncoder().Bytes(encoded)
+			assert.NoError(t, err)
+		}
+
+		encoded = append(append([]byte{}, bom.bom...), encoded...)
+
+		// The formatted content is detected again with the same settings and is left as is
+		for policy, encoding := range map[string]string{BOMKeep: bom.name, BOMAdd: bom.name, BOMStrip: "UTF-8"} {
+			options := DefaultOptions()
+			options.BOM = policy
+			formatted := formatSource("test.feature", encoded, options)
+
+			assert.NoError(t, formatted.Err, bom.name+" "+policy)
+			assert.Equal(t, []any{bom.name, "crlf", true}, []any{formatted.Encoding, formatted.EOL, formatted.BOM})
+
+			result := check(formatSource("test.feature", formatted.Formatted, options))
+
+			assert.NoError(t, result.Err, bom.name+" "+policy)
+			assert.Equal(t, StatusUnchanged, result.Status, bom.name+" "+policy)
+			assert.Equal(
+				t,
+				[]any{encoding, "crlf", policy != BOMStrip, false},
+				[]any{result.Encoding, result.EOL, result.BOM, result.Converted},
+				bom.name+" "+policy,
+			)
+		}
+	}
+}
+
+func TestFormatBytesBOMAndFinalNewline(t *testing.T) {
+	src := []byte("\xef\xbb\xbfFeature: test\n\n  Scenario: scenario1\n")
+
+	options := DefaultOptions()
+	options.BOM = BOMStrip
+	b, err := FormatBytes(src, options)
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, "Feature: test\n\n  Scenario: scenario1\n", b)
+
+	options.BOM = BOMAdd
+	b, err = FormatBytes(src[3:], options)
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, src, b)
+
+	options.BOM = BOMKeep
+	options.FinalNewline = FinalNewlineStrip
+	b, err = FormatBytes(src, options)
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, src[:len(src)-1], b)
+
+	options.FinalNewline = FinalNewlineKeep
+	b, err = FormatBytes([]byte("Feature: test\r\n\r\nScenario: scenario1"), options)
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, "Feature: test\r\n\r\n  Scenario: scenario1", b)
+
+	options.FinalNewline = "whatever"
+	_, err = FormatBytes(src, options)
+
+	assert.EqualError(t, err, `unknown final newline policy "whatever", expected "keep", "strip" or "add"`)
+
+	// Policies are checked even when the feature is properly formatted
+	options = DefaultOptions()
+	options.BOM = BOMStrip
+	result := check(formatSource("test.feature", src[:len(src)-1], options))
+
+	assert.Equal(t, StatusUnformatted, result.Status)
+	assert.ErrorContains(
+		t,
+		result.Err,
+		"file is not properly formatted, the BOM must be removed, the final newline is missing",
+	)
+}
+
+func TestFormatBytesInvalidOptions(t *testing.T) {
+	_, err := FormatBytes([]byte("Feature: test\n"), Options{})
+
+	assert.EqualError(t, err, "indent must be at least 1, got 0")
+
+	var out bytes.Buffer
+
+	err = FormatReader(strings.NewReader("Feature: test\n"), &out, Options{Indent: -1})
+
+	assert.EqualError(t, err, "indent must be at least 1, got -1")
+	assert.Empty(t, out.String())
+}
@@ -0,0 +1,255 @@
+package formatter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	mpath "path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is the time to wait for a file to stop changing before formatting it
+const watchDebounce = 100 * time.Millisecond
+
+// watchedFolder is a folder given to watch, along with the rules of the files to skip in it
+type watchedFolder struct {
+	abs     string
+	ignorer *ignorer
+}
+
+// folderWatcher formats the feature files written in watched folders
+type folderWatcher struct {
+	fileManager FileManager
+	watcher     *fsnotify.Watcher
+	folders     []watchedFolder
+	// pending holds the files written since the last time files were formatted
+	pending map[string]bool
+	// written holds the content of the files formatted, to skip the events raised by their rewrite
+	written map[string][]byte
+}
+
+// Watch formats the feature files of folders and their sub folders each time they are written, until the context
+// is done. Files and folders skipped when walking folders are not watched. The result of each file processed
+// is given to report, events raised by the rewrite of a formatted file are skipped
+func (f FileManager) Watch(ctx context.Context, folders []string, report func(result Result)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	defer watcher.Close()
+
+	w := &folderWatcher{fileManager: f, watcher: watcher, pending: map[string]bool{}, written: map[string][]byte{}}
+
+	for _, folder := range folders {
+		if err := w.addFolder(folder); err != nil {
+			return err
+		}
+	}
+
+	// Only the files written from now on are formatted
+	w.pending = map[string]bool{}
+
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+
+			report(Result{Status: StatusIOError, Err: err, unprocessed: true})
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			if !w.handle(event) {
+				continue
+			}
+
+			// The timer is drained before being reset, a pending tick would format files still being written
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+
+			timer.Reset(watchDebounce)
+		case <-timer.C:
+			for _, result := range w.formatPending() {
+				report(result)
+			}
+		}
+	}
+}
+
+// addFolder watches a folder given to watch and its sub folders
+func (w *folderWatcher) addFolder(folder string) error {
+	info, err := os.Stat(folder)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a folder", folder)
+	}
+
+	abs, err := filepath.Abs(folder)
+	if err != nil {
+		return err
+	}
+
+	ignorer, err := newIgnorer(abs, w.fileManager.excludes, w.fileManager.respectGitIgnore)
+	if err != nil {
+		return err
+	}
+
+	w.folders = append(w.folders, watchedFolder{abs: abs, ignorer: ignorer})
+
+	return w.add(folder)
+}
+
+// add watches a folder and its sub folders that are not skipped. Feature files already in them are marked
+// as pending, they may have been written before the folders were watched
+func (w *folderWatcher) add(folder string) error {
+	return filepath.WalkDir(folder, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		watched, abs, ok := w.folderOf(p)
+		if !ok {
+			return nil
+		}
+
+		if !d.IsDir() {
+			if w.isFeatureFile(p, abs, watched) {
+				w.pending[p] = true
+			}
+
+			return nil
+		}
+
+		if abs != watched.abs && watched.ignorer.isIgnored(abs, true) {
+			return filepath.SkipDir
+		}
+
+		if err := watched.ignorer.read(abs); err != nil {
+			return err
+		}
+
+		return w.watcher.Add(p)
+	})
+}
+
+// handle records a feature file as pending when it is written and watches the folders created.
+// It returns whether a file became pending
+func (w *folderWatcher) handle(event fsnotify.Event) bool {
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		delete(w.written, event.Name)
+	}
+
+	if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+		return false
+	}
+
+	if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+		// Errors are ignored, the folder may already be removed
+		pending := len(w.pending)
+		_ = w.add(event.Name)
+
+		return len(w.pending) > pending
+	}
+
+	watched, abs, ok := w.folderOf(event.Name)
+	if !ok || !w.isFeatureFile(event.Name, abs, watched) {
+		return false
+	}
+
+	w.pending[event.Name] = true
+
+	return true
+}
+
+// formatPending formats the pending files in path order
+func (w *folderWatcher) formatPending() Results {
+	var results Results
+
+	files := make([]string, 0, len(w.pending))
+	for file := range w.pending {
+		files = append(files, file)
+	}
+
+	sort.Strings(files)
+
+	w.pending = map[string]bool{}
+
+	for _, file := range files {
+		// The content written is only needed to skip the events of its rewrite
+		written, ok := w.written[file]
+		delete(w.written, file)
+
+		content, err := os.ReadFile(file)
+		if err != nil || ok && bytes.Equal(content, written) {
+			continue
+		}
+
+		result := w.fileManager.processFile(file, nil, replaceFileWithContent)
+		if result.Status == StatusReformatted {
+			w.written[file] = result.Formatted
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// isFeatureFile tells whether a file is a feature file that is not skipped
+func (w *folderWatcher) isFeatureFile(file string, abs string, watched watchedFolder) bool {
+	if mpath.Ext(file) != ".feature" || watched.ignorer.isIgnored(abs, false) {
+		return false
+	}
+
+	config, err := w.fileManager.Config(file)
+
+	return err != nil || !config.isExcluded(file)
+}
+
+// folderOf returns the absolute path of a file along with the closest folder given to watch it belongs to
+func (w *folderWatcher) folderOf(file string) (watchedFolder, string, bool) {
+	var (
+		watched watchedFolder
+		found   bool
+	)
+
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return watched, "", false
+	}
+
+	for _, folder := range w.folders {
+		if abs != folder.abs && !strings.HasPrefix(abs, folder.abs+string(filepath.Separator)) {
+			continue
+		}
+
+		if !found || len(folder.abs) > len(watched.abs) {
+			watched, found = folder, true
+		}
+	}
+
+	return watched, abs, found
+}
@@ -13,6 +13,15 @@ func Valid(data []byte) bool {
 	return checkValid(data, scan) == nil
 }
 
+// Validate checks whether data is a valid JSON encoding and returns a *SyntaxError describing
+// the first error found otherwise.
+func Validate(data []byte) error {
+	scan := newScanner()
+	defer freeScanner(scan)
+
+	return checkValid(data, scan)
+}
+
 func checkValid(data []byte, scan *scanner) error {
 	scan.reset()
 
@@ -30,9 +39,10 @@ func checkValid(data []byte, scan *scanner) error {
 	return nil
 }
 
+// A SyntaxError is a description of a JSON syntax error.
 type SyntaxError struct {
-	msg    string
-	Offset int64
+	msg    string // description of error
+	Offset int64  // error occurred after reading Offset bytes
 }
 
 func (e *SyntaxError) Error() string { return e.msg }
@@ -57,6 +57,27 @@ func TestValid(t *testing.T) {
 	}
 }
 
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		CaseName
+		data string
+		err  error
+	}{
+		{Name(""), `{"foo":"bar"}`, nil},
+		{Name(""), `{"foo":<bar>}`, nil},
+		{Name(""), `{"foo":"bar",}`, &SyntaxError{"invalid character '}' looking for beginning of value", 14}},
+		{Name(""), `[1,}`, &SyntaxError{"invalid character '}' looking for beginning of value", 4}},
+		{Name(""), `{"foo"`, &SyntaxError{"unexpected end of JSON input", 6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			if err := Validate([]byte(tt.data)); !reflect.DeepEqual(err, tt.err) {
+				t.Errorf("%s: Validate(`%s`):\n\tgot:  %v\n\twant: %v", tt.Where, tt.data, err, tt.err)
+			}
+		})
+	}
+}
+
 func TestIndent(t *testing.T) {
 	tests := []struct {
 		CaseName
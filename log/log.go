@@ -16,10 +16,18 @@ func Error(err error) {
 	log.error(err)
 }
 
+func Warning(err error) {
+	log.warning(err)
+}
+
 func Success(str string) {
 	log.success(str)
 }
 
+func Info(str string) {
+	log.info(str)
+}
+
 // GetLogger using for test purposes only. Do not use it for production code
 func GetLogger() *baselog.Logger {
 	return logger
@@ -29,6 +37,14 @@ func (l logging) error(err error) {
 	logger.Println(color.New(color.FgRed).Sprint(err.Error()))
 }
 
+func (l logging) warning(err error) {
+	logger.Println(color.New(color.FgYellow).Sprint(err.Error()))
+}
+
 func (l logging) success(str string) {
 	logger.Println(color.New(color.FgGreen).Sprint(str))
 }
+
+func (l logging) info(str string) {
+	logger.Println(str)
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 
 	"github.com/judimator/augurken/cmd"
+	"github.com/judimator/augurken/cmd/exitcode"
 )
 
 var exitFn = os.Exit
@@ -16,8 +18,14 @@ func run() int {
 
 	// `err` just helps to guess whether command successful or not. To see proper log result DO NOT log anything here
 	if err := command.Execute(); err != nil {
-		return 1
+		var exitError exitcode.Error
+		if errors.As(err, &exitError) {
+			return exitError.Code
+		}
+
+		// Invalid command lines and errors preventing files from being processed
+		return exitcode.Failure
 	}
 
-	return 0
+	return exitcode.OK
 }
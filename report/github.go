@@ -0,0 +1,49 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/judimator/augurken/formatter"
+)
+
+// githubDataEscaper escapes the message of a GitHub Actions workflow command
+var githubDataEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+
+// githubPropertyEscaper escapes the properties of a GitHub Actions workflow command
+var githubPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+
+// writeGitHub writes a GitHub Actions workflow command for each problem found in files, so they are shown
+// as annotations on the lines they concern: ::error file=...,line=...::message
+func writeGitHub(w io.Writer, results formatter.Results) error {
+	for _, result := range results {
+		for _, p := range problems(result) {
+			properties := []string{"file=" + githubPropertyEscaper.Replace(uri(result.Path))}
+
+			if p.line > 0 {
+				properties = append(properties, "line="+strconv.Itoa(p.line), "endLine="+strconv.Itoa(p.endLine))
+			}
+
+			if p.column > 0 {
+				properties = append(properties, "col="+strconv.Itoa(p.column))
+			}
+
+			properties = append(properties, "title="+githubPropertyEscaper.Replace("augurken "+p.rule))
+
+			_, err := fmt.Fprintf(
+				w,
+				"::%s %s::%s\n",
+				p.level,
+				strings.Join(properties, ","),
+				githubDataEscaper.Replace(p.message),
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
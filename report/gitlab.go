@@ -0,0 +1,76 @@
+package report
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/judimator/augurken/formatter"
+)
+
+// gitlabIssue is an issue of a GitLab Code Quality report
+type gitlabIssue struct {
+	Description string         `json:"description"`
+	CheckName   string         `json:"check_name"`
+	Fingerprint string         `json:"fingerprint"`
+	Severity    string         `json:"severity"`
+	Location    gitlabLocation `json:"location"`
+}
+
+type gitlabLocation struct {
+	Path  string      `json:"path"`
+	Lines gitlabLines `json:"lines"`
+}
+
+type gitlabLines struct {
+	Begin int `json:"begin"`
+}
+
+// gitlabSeverities maps the level of a problem to the severity of a GitLab Code Quality issue
+var gitlabSeverities = map[string]string{
+	levelError:   "major",
+	levelWarning: "minor",
+}
+
+// writeGitLab writes a GitLab Code Quality report with an issue for each problem found in files. Problems
+// concerning a whole file are located on its first line
+func writeGitLab(w io.Writer, results formatter.Results) error {
+	issues := []gitlabIssue{}
+
+	for _, result := range results {
+		// occurrences counts the problems of the file with the same rule and message
+		occurrences := map[string]int{}
+
+		for _, p := range problems(result) {
+			path := uri(result.Path)
+			line := max(p.line, 1)
+
+			// The fingerprint identifies an issue from a run to another, it doesn't depend on its location
+			// so that an issue moved by lines added above it is the same issue
+			key := p.rule + "\x00" + p.message
+			occurrence := occurrences[key]
+			occurrences[key]++
+
+			h := sha256.New()
+			for _, s := range []string{path, p.rule, p.message, strconv.Itoa(occurrence)} {
+				h.Write([]byte(s))
+				h.Write([]byte{0})
+			}
+
+			issues = append(issues, gitlabIssue{
+				Description: p.message,
+				CheckName:   "augurken/" + p.rule,
+				Fingerprint: hex.EncodeToString(h.Sum(nil)),
+				Severity:    gitlabSeverities[p.level],
+				Location:    gitlabLocation{Path: path, Lines: gitlabLines{Begin: line}},
+			})
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(issues)
+}
@@ -0,0 +1,88 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/judimator/augurken/formatter"
+)
+
+// jsonReport is the JSON document written for a run
+type jsonReport struct {
+	Files   []jsonFile  `json:"files"`
+	Summary jsonSummary `json:"summary"`
+}
+
+type jsonFile struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Errors locates the errors of the gherkin parser, when the file is not a valid feature
+	Errors      []jsonDiagnostic `json:"errors,omitempty"`
+	Diagnostics []jsonDiagnostic `json:"diagnostics"`
+}
+
+// jsonDiagnostic is a message located in a file, from line 1 and column 1
+type jsonDiagnostic struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+type jsonSummary struct {
+	Files       int `json:"files"`
+	Unchanged   int `json:"unchanged"`
+	Reformatted int `json:"reformatted"`
+	Unformatted int `json:"unformatted"`
+	ParseErrors int `json:"parseErrors"`
+	IOErrors    int `json:"ioErrors"`
+	OtherErrors int `json:"otherErrors"`
+	Diagnostics int `json:"diagnostics"`
+}
+
+// writeJSON writes a JSON document listing each file, its status and its diagnostics, followed by totals
+func writeJSON(w io.Writer, results formatter.Results) error {
+	summary := results.Summary()
+	report := jsonReport{
+		Files: make([]jsonFile, 0, len(results)),
+		Summary: jsonSummary{
+			Files:       summary.Files,
+			Unchanged:   summary.Unchanged,
+			Reformatted: summary.Reformatted,
+			Unformatted: summary.Unformatted,
+			ParseErrors: summary.ParseErrors,
+			IOErrors:    summary.IOErrors,
+			OtherErrors: summary.OtherErrors,
+			Diagnostics: summary.Diagnostics,
+		},
+	}
+
+	for _, result := range results {
+		file := jsonFile{Path: result.Path, Status: result.Status.ID(), Diagnostics: []jsonDiagnostic{}}
+
+		if result.Err != nil {
+			file.Error = message(result.Err)
+		}
+
+		if result.Status == formatter.StatusParseError {
+			for _, p := range parseErrors(result.Err) {
+				file.Errors = append(file.Errors, jsonDiagnostic{Line: p.line, Column: p.column, Message: p.message})
+			}
+		}
+
+		for _, diagnostic := range result.Diagnostics {
+			file.Diagnostics = append(file.Diagnostics, jsonDiagnostic{
+				Line:    diagnostic.Line,
+				Column:  diagnostic.Column,
+				Message: diagnostic.Message,
+			})
+		}
+
+		report.Files = append(report.Files, file)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(report)
+}
@@ -0,0 +1,101 @@
+package report
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/judimator/augurken/formatter"
+)
+
+// junitTestSuites is the JUnit XML document written for a run, each file is a test case
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+// junitProblem is a failure, when the file is not properly formatted or can't be parsed, or an error,
+// when the file can't be processed
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",cdata"`
+}
+
+// writeJUnit writes a JUnit XML document with a test case for each file. Files that are not properly formatted
+// fail with the diff of the changes needed, files that can't be parsed fail with the parse error
+func writeJUnit(w io.Writer, results formatter.Results) error {
+	summary := results.Summary()
+	suite := junitTestSuite{
+		Name:      "augurken",
+		Tests:     len(results),
+		Failures:  summary.Unformatted + summary.ParseErrors,
+		Errors:    summary.IOErrors + summary.OtherErrors,
+		TestCases: make([]junitTestCase, 0, len(results)),
+	}
+
+	for _, result := range results {
+		testCase := junitTestCase{Name: result.Path, ClassName: "augurken"}
+
+		switch result.Status {
+		case formatter.StatusUnformatted:
+			testCase.Failure = &junitProblem{Message: message(result.Err), Type: result.Status.ID(), Text: result.Diff()}
+		case formatter.StatusParseError:
+			testCase.Failure = &junitProblem{Message: message(result.Err), Type: result.Status.ID(), Text: message(result.Err)}
+		case formatter.StatusIOError:
+			testCase.Error = &junitProblem{Message: message(result.Err), Type: result.Status.ID(), Text: message(result.Err)}
+		case formatter.StatusUnchanged, formatter.StatusReformatted:
+		}
+
+		var diagnostics strings.Builder
+		for _, diagnostic := range result.Diagnostics {
+			diagnostics.WriteString(diagnostic.Error() + "\n")
+		}
+
+		testCase.SystemOut = diagnostics.String()
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	document := junitTestSuites{
+		Name:     suite.Name,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Suites:   []junitTestSuite{suite},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
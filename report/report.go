@@ -0,0 +1,199 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/judimator/augurken/formatter"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Formats of the reports written from the results of a run
+const (
+	// FormatText is the human-readable log output, it is not written as a report
+	FormatText = "text"
+	// FormatJSON is a JSON document listing each file along with its diagnostics, followed by totals
+	FormatJSON = "json"
+	// FormatJUnit is a JUnit XML document with a test case for each file
+	FormatJUnit = "junit"
+	// FormatSARIF is a SARIF 2.1.0 log for code scanning tools
+	FormatSARIF = "sarif"
+	// FormatGitHub is a list of GitHub Actions workflow commands annotating the lines of the problems found
+	FormatGitHub = "github"
+	// FormatGitLab is a GitLab Code Quality report
+	FormatGitLab = "gitlab"
+)
+
+// gherkinErrorLocation matches the location starting each error reported by the gherkin parser: (line:column)
+var gherkinErrorLocation = regexp.MustCompile(`^\((\d+):(\d+)\): (.*)$`)
+
+// problem is a problem found in a file, located from line 1 and column 1. The line is 0 when the problem
+// concerns the whole file and the column is 0 when it concerns whole lines
+type problem struct {
+	rule    string
+	level   string
+	line    int
+	endLine int
+	column  int
+	message string
+}
+
+// Rules of the problems found in files, the rules of failed files are the identifiers of their status
+const (
+	ruleUnformatted = "unformatted"
+	ruleParseError  = "parse-error"
+	ruleIOError     = "io-error"
+	ruleDiagnostic  = "diagnostic"
+)
+
+// Levels of the problems found in files
+const (
+	levelError   = "error"
+	levelWarning = "warning"
+)
+
+// Write writes a report of results in a format to w
+func Write(w io.Writer, format string, results formatter.Results) error {
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, results)
+	case FormatJUnit:
+		return writeJUnit(w, results)
+	case FormatSARIF:
+		return writeSARIF(w, results)
+	case FormatGitHub:
+		return writeGitHub(w, results)
+	case FormatGitLab:
+		return writeGitLab(w, results)
+	default:
+		return fmt.Errorf(`unknown report format "%s"`, format)
+	}
+}
+
+// WriteFile writes a report of results in a format to a file
+func WriteFile(filename string, format string, results formatter.Results) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := Write(f, format, results); err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	return f.Close()
+}
+
+// message returns the reason a file failed, without the name of the file
+func message(err error) string {
+	var processFileError formatter.ProcessFileError
+	if errors.As(err, &processFileError) {
+		return processFileError.Message
+	}
+
+	return err.Error()
+}
+
+// problems lists the problems found in a file: the lines to change when it is not properly formatted,
+// the errors of the gherkin parser, the error that prevented it from being processed and its diagnostics
+func problems(result formatter.Result) []problem {
+	var found []problem
+
+	switch result.Status {
+	case formatter.StatusUnformatted:
+		found = append(found, changes(result)...)
+	case formatter.StatusParseError:
+		found = append(found, parseErrors(result.Err)...)
+	case formatter.StatusIOError:
+		found = append(found, problem{rule: ruleIOError, level: levelError, message: message(result.Err)})
+	case formatter.StatusUnchanged, formatter.StatusReformatted:
+	}
+
+	for _, diagnostic := range result.Diagnostics {
+		found = append(found, problem{
+			rule:    ruleDiagnostic,
+			level:   levelWarning,
+			line:    diagnostic.Line,
+			endLine: diagnostic.Line,
+			column:  diagnostic.Column,
+			message: diagnostic.Message,
+		})
+	}
+
+	return found
+}
+
+// changes locates each block of lines of the original content changed by formatting
+func changes(result formatter.Result) []problem {
+	original := strings.SplitAfter(string(result.Original), "\n")
+	formatted := strings.SplitAfter(string(result.Formatted), "\n")
+
+	// The content ending with a newline is split with an empty last element, which is not a line
+	lines := len(original)
+	if original[lines-1] == "" {
+		lines--
+	}
+
+	var found []problem
+
+	for _, group := range difflib.NewMatcher(original, formatted).GetGroupedOpCodes(0) {
+		// Lines inserted are located on the line they are inserted before, or on the last line at the end
+		line := min(group[0].I1+1, lines)
+		found = append(found, problem{
+			rule:    ruleUnformatted,
+			level:   levelError,
+			line:    line,
+			endLine: max(min(group[len(group)-1].I2, lines), line),
+			message: message(result.Err),
+		})
+	}
+
+	// The content changes while its lines don't, like when only its BOM changes
+	if len(found) == 0 {
+		found = append(found, problem{rule: ruleUnformatted, level: levelError, message: message(result.Err)})
+	}
+
+	return found
+}
+
+// parseErrors locates the errors reported by the gherkin parser, formatted as "(line:column): message"
+func parseErrors(err error) []problem {
+	var found []problem
+
+	for _, line := range strings.Split(message(err), "\n") {
+		matches := gherkinErrorLocation.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+
+		l, _ := strconv.Atoi(matches[1])
+		c, _ := strconv.Atoi(matches[2])
+		found = append(found, problem{
+			rule:    ruleParseError,
+			level:   levelError,
+			line:    l,
+			endLine: l,
+			column:  c,
+			message: matches[3],
+		})
+	}
+
+	if len(found) == 0 {
+		found = append(found, problem{rule: ruleParseError, level: levelError, message: message(err)})
+	}
+
+	return found
+}
+
+// uri returns the slash separated path of a file used in reports
+func uri(file string) string {
+	return filepath.ToSlash(file)
+}
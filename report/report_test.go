@@ -0,0 +1,308 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/judimator/augurken/formatter"
+	"github.com/stretchr/testify/assert"
+)
+
+func results() formatter.Results {
+	return formatter.Results{
+		{
+			Path:      "features/a.feature",
+			Status:    formatter.StatusUnformatted,
+			Original:  []byte("Feature: a\nScenario: a\n"),
+			Formatted: []byte("Feature: a\n\n  Scenario: a\n"),
+			Err:       formatter.ProcessFileError{Message: "file is not properly formatted", File: "features/a.feature"},
+		},
+		{
+			Path:   "features/b.feature",
+			Status: formatter.StatusUnchanged,
+			Diagnostics: []formatter.Diagnostic{
+				{File: "features/b.feature", Line: 4, Column: 7, Message: "invalid character '}' looking for beginning of value"},
+			},
+		},
+		{
+			Path:   "features/c.feature",
+			Status: formatter.StatusParseError,
+			Err: formatter.ProcessFileError{
+				Message: "Parser errors:\n(3:1): expected: #EOF, got 'whatever'",
+				File:    "features/c.feature",
+				Err:     errors.New("Parser errors:\n(3:1): expected: #EOF, got 'whatever'"),
+			},
+		},
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+
+	assert.NoError(t, Write(&buf, FormatJSON, results()))
+	assert.JSONEq(t, `{
+  "files": [
+    {
+      "path": "features/a.feature",
+      "status": "unformatted",
+      "error": "file is not properly formatted",
+      "diagnostics": []
+    },
+    {
+      "path": "features/b.feature",
+      "status": "unchanged",
+      "diagnostics": [
+        {"line": 4, "column": 7, "message": "invalid character '}' looking for beginning of value"}
+      ]
+    },
+    {
+      "path": "features/c.feature",
+      "status": "parse-error",
+      "error": "Parser errors:\n(3:1): expected: #EOF, got 'whatever'",
+      "errors": [
+        {"line": 3, "column": 1, "message": "expected: #EOF, got 'whatever'"}
+      ],
+      "diagnostics": []
+    }
+  ],
+  "summary": {
+    "files": 3,
+    "unchanged": 1,
+    "reformatted": 0,
+    "unformatted": 1,
+    "parseErrors": 1,
+    "ioErrors": 0,
+    "otherErrors": 0,
+    "diagnostics": 1
+  }
+}`, buf.String())
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+
+	assert.EqualError(t, Write(&buf, "whatever", results()), `unknown report format "whatever"`)
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+
+	assert.NoError(t, Write(&buf, FormatJUnit, results()))
+
+	// Diffs give paths relative to the root of the repository
+	name := diffPrefix(t) + "features/a.feature"
+	assert.EqualValues(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="augurken" tests="3" failures="2" errors="0">
+  <testsuite name="augurken" tests="3" failures="2" errors="0">
+    <testcase name="features/a.feature" classname="augurken">
+      <failure message="file is not properly formatted" type="unformatted"><![CDATA[diff --git a/`+name+` b/`+name+`
+--- a/`+name+`
++++ b/`+name+`
+@@ -1,2 +1,3 @@
+ Feature: a
+-Scenario: a
++
++  Scenario: a
+]]></failure>
+    </testcase>
+    <testcase name="features/b.feature" classname="augurken">
+      <system-out>features/b.feature:4:7: invalid character &#39;}&#39; looking for beginning of value&#xA;</system-out>
+    </testcase>
+    <testcase name="features/c.feature" classname="augurken">
+      <failure message="Parser errors:&#xA;(3:1): expected: #EOF, got &#39;whatever&#39;" type="parse-error"><![CDATA[Parser errors:
+(3:1): expected: #EOF, got 'whatever']]></failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, buf.String())
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+
+	assert.NoError(t, Write(&buf, FormatSARIF, results()))
+	assert.JSONEq(t, `{
+  "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+  "version": "2.1.0",
+  "runs": [
+    {
+      "tool": {
+        "driver": {
+          "name": "augurken",
+          "version": "local",
+          "informationUri": "https://github.com/judimator/augurken",
+          "rules": [
+            {"id": "unformatted", "shortDescription": {"text": "The feature file is not properly formatted"}},
+            {"id": "parse-error", "shortDescription": {"text": "The feature file is not a valid Gherkin feature"}},
+            {"id": "io-error", "shortDescription": {"text": "The feature file could not be processed"}},
+            {
+              "id": "diagnostic",
+              "shortDescription": {
+                "text": "The feature file contains a problem that doesn't prevent it from being formatted"
+              }
+            }
+          ]
+        }
+      },
+      "results": [
+        {
+          "ruleId": "unformatted",
+          "level": "error",
+          "message": {"text": "file is not properly formatted"},
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {"uri": "features/a.feature"},
+                "region": {"startLine": 2, "endLine": 2}
+              }
+            }
+          ]
+        },
+        {
+          "ruleId": "diagnostic",
+          "level": "warning",
+          "message": {"text": "invalid character '}' looking for beginning of value"},
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {"uri": "features/b.feature"},
+                "region": {"startLine": 4, "startColumn": 7, "endLine": 4}
+              }
+            }
+          ]
+        },
+        {
+          "ruleId": "parse-error",
+          "level": "error",
+          "message": {"text": "expected: #EOF, got 'whatever'"},
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {"uri": "features/c.feature"},
+                "region": {"startLine": 3, "startColumn": 1, "endLine": 3}
+              }
+            }
+          ]
+        }
+      ]
+    }
+  ]
+}`, buf.String())
+}
+
+func TestWriteGitHub(t *testing.T) {
+	var buf bytes.Buffer
+
+	assert.NoError(t, Write(&buf, FormatGitHub, results()))
+	assert.EqualValues(
+		t,
+		"::error file=features/a.feature,line=2,endLine=2,title=augurken unformatted::file is not properly formatted\n"+
+			"::warning file=features/b.feature,line=4,endLine=4,col=7,title=augurken diagnostic::"+
+			"invalid character '}' looking for beginning of value\n"+
+			"::error file=features/c.feature,line=3,endLine=3,col=1,title=augurken parse-error::"+
+			"expected: #EOF, got 'whatever'\n",
+		buf.String(),
+	)
+}
+
+func TestWriteGitLab(t *testing.T) {
+	var buf bytes.Buffer
+
+	assert.NoError(t, Write(&buf, FormatGitLab, results()))
+
+	var issues []map[string]any
+
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &issues))
+	assert.Len(t, issues, 3)
+
+	for _, issue := range issues {
+		assert.Len(t, issue["fingerprint"], 64)
+		delete(issue, "fingerprint")
+	}
+
+	assert.Equal(t, []map[string]any{
+		{
+			"description": "file is not properly formatted",
+			"check_name":  "augurken/unformatted",
+			"severity":    "major",
+			"location":    map[string]any{"path": "features/a.feature", "lines": map[string]any{"begin": 2.0}},
+		},
+		{
+			"description": "invalid character '}' looking for beginning of value",
+			"check_name":  "augurken/diagnostic",
+			"severity":    "minor",
+			"location":    map[string]any{"path": "features/b.feature", "lines": map[string]any{"begin": 4.0}},
+		},
+		{
+			"description": "expected: #EOF, got 'whatever'",
+			"check_name":  "augurken/parse-error",
+			"severity":    "major",
+			"location":    map[string]any{"path": "features/c.feature", "lines": map[string]any{"begin": 3.0}},
+		},
+	}, issues)
+}
+
+func TestWriteGitLabFingerprint(t *testing.T) {
+	fingerprints := func(original string, formatted string) []any {
+		var buf bytes.Buffer
+
+		assert.NoError(t, Write(&buf, FormatGitLab, formatter.Results{{
+			Path:      "features/a.feature",
+			Status:    formatter.StatusUnformatted,
+			Original:  []byte(original),
+			Formatted: []byte(formatted),
+			Err:       formatter.ProcessFileError{Message: "file is not properly formatted", File: "features/a.feature"},
+		}}))
+
+		var issues []map[string]any
+
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &issues))
+
+		var found []any
+		for _, issue := range issues {
+			found = append(found, issue["fingerprint"])
+		}
+
+		return found
+	}
+
+	steps := "    Given a\n    Given b\n    Given c\n"
+	formatted := "Feature: a\n\n  Scenario: a\n" + steps + "\n  Scenario: b\n"
+
+	// Issues don't depend on their location, lines added above them don't change them
+	found := fingerprints("Feature: a\n\n  Scenario: a\n"+steps+"\nScenario: b\n", formatted)
+	moved := fingerprints("Feature: a\n# a\n\n  Scenario: a\n"+steps+"\nScenario: b\n", strings.Replace(formatted, "\n", "\n# a\n", 1))
+
+	assert.Len(t, found, 1)
+	assert.Equal(t, found, moved)
+
+	// Issues with the same rule and message differ by their occurrence
+	found = fingerprints("Feature: a\n\nScenario: a\n"+steps+"\nScenario: b\n", formatted)
+
+	assert.Len(t, found, 2)
+	assert.NotEqual(t, found[0], found[1])
+}
+
+func TestRules(t *testing.T) {
+	// Failed files are reported with the same identifier in every report
+	assert.Equal(t, ruleUnformatted, formatter.StatusUnformatted.ID())
+	assert.Equal(t, ruleParseError, formatter.StatusParseError.ID())
+	assert.Equal(t, ruleIOError, formatter.StatusIOError.ID())
+}
+
+// diffPrefix returns the working directory relative to the root of its git repository, as it starts the paths
+// in the headers of diffs
+func diffPrefix(t *testing.T) string {
+	t.Helper()
+
+	prefix, err := exec.Command("git", "rev-parse", "--show-prefix").Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(prefix))
+}
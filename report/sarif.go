@@ -0,0 +1,118 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/judimator/augurken/formatter"
+	"github.com/judimator/augurken/meta"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+)
+
+// sarifLog is the SARIF 2.1.0 log written for a run
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+}
+
+// sarifRules describes the rules of the problems found in files
+var sarifRules = []sarifRule{
+	{ID: ruleUnformatted, ShortDescription: sarifMessage{Text: "The feature file is not properly formatted"}},
+	{ID: ruleParseError, ShortDescription: sarifMessage{Text: "The feature file is not a valid Gherkin feature"}},
+	{ID: ruleIOError, ShortDescription: sarifMessage{Text: "The feature file could not be processed"}},
+	{ID: ruleDiagnostic, ShortDescription: sarifMessage{Text: "The feature file contains a problem that doesn't prevent it from being formatted"}},
+}
+
+// writeSARIF writes a SARIF 2.1.0 log with a result for each problem found in files: lines to format,
+// gherkin parse errors, JSON doc string syntax errors and other diagnostics
+func writeSARIF(w io.Writer, results formatter.Results) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "augurken",
+			Version:        meta.Version(),
+			InformationURI: "https://github.com/judimator/augurken",
+			Rules:          sarifRules,
+		}},
+		Results: []sarifResult{},
+	}
+
+	for _, result := range results {
+		for _, p := range problems(result) {
+			location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: uri(result.Path)},
+			}}
+
+			if p.line > 0 {
+				location.PhysicalLocation.Region = &sarifRegion{StartLine: p.line, StartColumn: p.column, EndLine: p.endLine}
+			}
+
+			run.Results = append(run.Results, sarifResult{
+				RuleID:    p.rule,
+				Level:     p.level,
+				Message:   sarifMessage{Text: p.message},
+				Locations: []sarifLocation{location},
+			})
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(sarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []sarifRun{run}})
+}